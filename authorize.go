@@ -0,0 +1,68 @@
+package shallow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ForbiddenFieldsError is returned if the authorizer (see WithAuthorizer) denied any of the changes.
+// Dest is left untouched.
+type ForbiddenFieldsError struct {
+	// Keys of the denied changes, in the same format as the keys returned by Merge.
+	Keys []string
+	// Errs are the errors returned by the authorizer, in the same order as Keys.
+	Errs []error
+}
+
+func (e *ForbiddenFieldsError) Error() string {
+	return fmt.Sprintf("changing fields is forbidden: %v", strings.Join(e.Keys, ", "))
+}
+
+// WithAuthorizer can be used to authorize the changes of Merge. The authorizer is called with the key of every
+// field that Merge would change (in the same format as the keys returned by Merge) before changing any of them,
+// and with the context passed to MergeContext (context.Background() otherwise).
+// If it returns an error for any of the keys, Merge returns a *ForbiddenFieldsError without changing dest.
+//
+// Keys which are selected by the keys map but whose value would not change are not authorized.
+//
+// Honored by every function changing a struct: Merge, MergeContext, MergeT, MergeMap, MergePatchJSON,
+// MergeIfUnchanged, MergePreview, Merge3 (for the changes of theirs), Inverse.Apply and ApplyJSONPatch, which
+// authorizes every affected key, even if its value would not change.
+func WithAuthorizer(authorizer func(ctx context.Context, key string) error) Option {
+	return func(o *options) {
+		o.authorizer = authorizer
+	}
+}
+
+func withContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// authorize calls the authorizer for the given keys, returning a *ForbiddenFieldsError if any of them is denied.
+func (o *options) authorize(keys []string) error {
+	if o.authorizer == nil {
+		return nil
+	}
+
+	var forbiddenErr *ForbiddenFieldsError
+	for _, key := range keys {
+		err := o.authorizer(o.ctx, key)
+		if err == nil {
+			continue
+		}
+
+		if forbiddenErr == nil {
+			forbiddenErr = &ForbiddenFieldsError{}
+		}
+		forbiddenErr.Keys = append(forbiddenErr.Keys, key)
+		forbiddenErr.Errs = append(forbiddenErr.Errs, err)
+	}
+	if forbiddenErr != nil {
+		return forbiddenErr
+	}
+
+	return nil
+}
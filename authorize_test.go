@@ -0,0 +1,119 @@
+package shallow
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+type roleKey struct{}
+
+func authorizeAdmin(ctx context.Context, key string) error {
+	if key == "string" && ctx.Value(roleKey{}) != "admin" {
+		return errors.New("only admins can change string")
+	}
+
+	return nil
+}
+
+func TestMergeAuthorizer(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		ctx             context.Context
+		incoming        string
+		want            test
+		wantChangedKeys []string
+		wantForbidden   []string
+	}{
+		"allowed": {
+			ctx:      context.WithValue(context.Background(), roleKey{}, "admin"),
+			incoming: `{"string":"test2","bool":false}`,
+			want: testData(func(t test) test {
+				t.String = "test2"
+				t.Bool = false
+				return t
+			}),
+			wantChangedKeys: []string{"string", "bool"},
+		},
+		"forbidden": {
+			ctx:           context.WithValue(context.Background(), roleKey{}, "owner"),
+			incoming:      `{"string":"test2","bool":false}`,
+			want:          testData(nil),
+			wantForbidden: []string{"string"},
+		},
+		"unchanged": {
+			ctx:      context.WithValue(context.Background(), roleKey{}, "owner"),
+			incoming: `{"string":"string_val","bool":false}`,
+			want: testData(func(t test) test {
+				t.Bool = false
+				return t
+			}),
+			wantChangedKeys: []string{"bool"},
+		},
+	} {
+		update := test{}
+		err := json.Unmarshal([]byte(data.incoming), &update)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		var keys map[string]interface{}
+		err = json.Unmarshal([]byte(data.incoming), &keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		got := testData(nil)
+		gotChangedKeys, err := MergeContext(data.ctx, &got, &update, keys, WithAuthorizer(authorizeAdmin))
+		if len(data.wantForbidden) > 0 {
+			forbiddenErr, ok := err.(*ForbiddenFieldsError)
+			if !ok {
+				t.Fatalf("%v: expected *ForbiddenFieldsError, got: %v", name, err)
+			}
+			if diff := pretty.Diff(data.wantForbidden, forbiddenErr.Keys); len(diff) > 0 {
+				t.Errorf("%v forbidden: diffs (want/got): %v", name, diff)
+			}
+		} else if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+
+		if diff := pretty.Diff(data.want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, diff)
+		}
+	}
+}
+
+func TestApplyJSONPatchAuthorizer(t *testing.T) {
+	got := testData(nil)
+	_, err := ApplyJSONPatch(&got, []byte(`[{"op":"replace","path":"/bool","value":false},{"op":"replace","path":"/string","value":"test2"}]`), WithAuthorizer(authorizeAdmin))
+	forbiddenErr, ok := err.(*ForbiddenFieldsError)
+	if !ok {
+		t.Fatalf("expected *ForbiddenFieldsError, got: %v", err)
+	}
+	if diff := pretty.Diff([]string{"string"}, forbiddenErr.Keys); len(diff) > 0 {
+		t.Errorf("forbidden: diffs (want/got): %v", diff)
+	}
+	if diff := pretty.Diff(testData(nil), got); len(diff) > 0 {
+		t.Errorf("dest modified: %v", diff)
+	}
+
+	gotAffectedKeys, err := ApplyJSONPatch(&got, []byte(`[{"op":"replace","path":"/bool","value":false}]`), WithAuthorizer(authorizeAdmin))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	want := testData(func(t test) test {
+		t.Bool = false
+		return t
+	})
+	if diff := pretty.Diff(want, got); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", diff)
+	}
+	if diff := pretty.Diff([]string{"bool"}, gotAffectedKeys); len(diff) > 0 {
+		t.Errorf("affectedKeys: diffs (want/got): %v", diff)
+	}
+}
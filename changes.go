@@ -0,0 +1,91 @@
+package shallow
+
+import (
+	"reflect"
+)
+
+// ChangeKind describes how the value of a field changed.
+type ChangeKind int
+
+const (
+	// ChangeModified means that the field changed from a non-nil value to another non-nil value.
+	// Fields of types which can't be nil are always reported as modified.
+	ChangeModified ChangeKind = iota
+	// ChangeSet means that the field changed from nil to a non-nil value, or that the element was added to a slice
+	// merged by key (see StrategyTag).
+	ChangeSet
+	// ChangeCleared means that the field changed from a non-nil value to nil, or that the element was removed from a
+	// slice merged by key.
+	ChangeCleared
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeModified:
+		return "modified"
+	case ChangeSet:
+		return "set"
+	case ChangeCleared:
+		return "cleared"
+	default:
+		return "unknown"
+	}
+}
+
+// Change describes a changed field.
+type Change struct {
+	// Key of the field, as returned by Diff and Merge.
+	Key string
+	// Path of the field, the tags of the fields leading to it (see Deep).
+	Path []string
+	// Old is the value in the first (Diff) or dest (Merge) struct.
+	Old interface{}
+	// New is the value in the second (Diff) or update (Merge) struct.
+	New  interface{}
+	Kind ChangeKind
+}
+
+// DiffChanges compares structs the same way as Diff, but returns the old and new values of the fields too.
+func DiffChanges(first interface{}, second interface{}, keys map[string]interface{}, opts ...Option) (changes []Change, err error) {
+	cs, err := processChanges(first, second, keys, false, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return exportChanges(cs), nil
+}
+
+func exportChanges(cs []change) []Change {
+	changes := make([]Change, 0, len(cs))
+	for _, c := range cs {
+		changes = append(changes, c.export())
+	}
+
+	return changes
+}
+
+func (c change) export() Change {
+	kind := ChangeModified
+	if c.op == PatchOpAdd || isNil(c.oldValue) {
+		kind = ChangeSet
+	} else if c.op == PatchOpRemove || isNil(c.newValue) {
+		kind = ChangeCleared
+	}
+
+	return Change{
+		Key:  c.key(),
+		Path: c.path.keys(),
+		Old:  c.oldValue.Interface(),
+		New:  c.newValue.Interface(),
+		Kind: kind,
+	}
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,100 @@
+package shallow
+
+import (
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+func TestDiffChanges(t *testing.T) {
+	first := testData(func(t test) test {
+		t.NestedPtr = nil
+		return t
+	})
+	second := testData(func(t test) test {
+		t.String = "test2"
+		t.StringPtr = nil
+		t.Nested.Bool = false
+		return t
+	})
+
+	gotChanges, err := DiffChanges(&first, &second, nil, Deep())
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	wantChanges := []Change{
+		{
+			Key:  "string",
+			Path: []string{"string"},
+			Old:  "string_val",
+			New:  "test2",
+			Kind: ChangeModified,
+		},
+		{
+			Key:  "string_ptr",
+			Path: []string{"string_ptr"},
+			Old:  stringPtr("string_ptr_val"),
+			New:  (*string)(nil),
+			Kind: ChangeCleared,
+		},
+		{
+			Key:  "nested.bool",
+			Path: []string{"nested", "bool"},
+			Old:  true,
+			New:  false,
+			Kind: ChangeModified,
+		},
+		{
+			Key:  "nested_ptr",
+			Path: []string{"nested_ptr"},
+			Old:  (*Nested)(nil),
+			New:  second.NestedPtr,
+			Kind: ChangeSet,
+		},
+	}
+	if diff := pretty.Diff(wantChanges, gotChanges); len(diff) > 0 {
+		t.Errorf("changes: diffs (want/got): %v", diff)
+	}
+}
+
+func TestMergeCollectChanges(t *testing.T) {
+	orig := testData(nil)
+	update := testData(func(t test) test {
+		t.String = "test2"
+		t.AnonymPtr2String = "test3"
+		return t
+	})
+
+	var gotChanges []Change
+	gotChangedKeys, err := Merge(&orig, &update, map[string]interface{}{"string": nil, "anonym_ptr2_string": nil, "bool": nil}, CollectChanges(&gotChanges))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	wantChanges := []Change{
+		{
+			Key:  "string",
+			Path: []string{"string"},
+			Old:  "string_val",
+			New:  "test2",
+			Kind: ChangeModified,
+		},
+		{
+			Key:  "anonym_ptr2_string",
+			Path: []string{"anonym_ptr2_string"},
+			Old:  "anonym_ptr_string_val",
+			New:  "test3",
+			Kind: ChangeModified,
+		},
+	}
+	if diff := pretty.Diff(wantChanges, gotChanges); len(diff) > 0 {
+		t.Errorf("changes: diffs (want/got): %v", diff)
+	}
+
+	wantChangedKeys := []string{"string", "anonym_ptr2_string"}
+	if diff := pretty.Diff(wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+		t.Errorf("changedKeys: diffs (want/got): %v", diff)
+	}
+}
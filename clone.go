@@ -0,0 +1,83 @@
+package shallow
+
+import (
+	"reflect"
+)
+
+// deepCopy returns a copy of v that shares no pointers, slices or maps with it.
+// Unexported fields are copied as they are (except for the exported fields of embedded structs),
+// and cyclic data structures are not supported.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+
+		return c
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+
+		return c
+
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		deepCopyFields(c, v)
+
+		return c
+
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+
+		return c
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+
+		return c
+
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+
+		return c
+
+	default:
+		return v
+	}
+}
+
+// deepCopyFields replaces the fields of the c struct with deep copies of the fields of v.
+func deepCopyFields(c reflect.Value, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		switch {
+		case c.Field(i).CanSet():
+			c.Field(i).Set(deepCopy(v.Field(i)))
+		case v.Type().Field(i).Anonymous && v.Field(i).Kind() == reflect.Struct:
+			deepCopyFields(c.Field(i), v.Field(i))
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package shallow
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kr/pretty"
+)
+
+func TestDeepCopy(t *testing.T) {
+	type withContainers struct {
+		Slice     []*Nested
+		Map       map[string]*Nested
+		Interface interface{}
+		Array     [1]*Nested
+		test
+	}
+
+	orig := withContainers{
+		Slice:     []*Nested{{String: "slice"}},
+		Map:       map[string]*Nested{"key": {String: "map"}},
+		Interface: &Nested{String: "interface"},
+		Array:     [1]*Nested{{String: "array"}},
+		test:      testData(nil),
+	}
+	want := withContainers{
+		Slice:     []*Nested{{String: "slice"}},
+		Map:       map[string]*Nested{"key": {String: "map"}},
+		Interface: &Nested{String: "interface"},
+		Array:     [1]*Nested{{String: "array"}},
+		test:      testData(nil),
+	}
+
+	got := deepCopy(reflect.ValueOf(orig)).Interface().(withContainers)
+	if diff := pretty.Diff(want, got); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", pretty.Diff(want, got))
+	}
+
+	got.Slice[0].String = "changed"
+	got.Map["key"].String = "changed"
+	got.Interface.(*Nested).String = "changed"
+	got.Array[0].String = "changed"
+	*got.StringPtr = "changed"
+	got.NestedPtr.String = "changed"
+	got.AnonymPtr2.AnonymPtr2String = "changed"
+	if diff := pretty.Diff(want, orig); len(diff) > 0 {
+		t.Errorf("original modified: diffs (want/got): %v", pretty.Diff(want, orig))
+	}
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/proemergotech/errors/v2"
+	"github.com/proemergotech/shallow"
+	"github.com/proemergotech/shallow/internal/jsonfield"
+)
+
+const generatedMarker = "// Code generated by shallowgen"
+
+// generate returns the source of the Diff and Merge functions for the given types of the package in dir.
+func generate(dir string, typeNames []string, tag string, args string) ([]byte, error) {
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{
+		pkg:        pkg,
+		tag:        tag,
+		jsonNames:  tag == "json",
+		collecting: make(map[*types.Struct]bool),
+		imports:    make(map[string]string),
+	}
+	for _, typeName := range typeNames {
+		err = g.generateType(typeName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	header := &bytes.Buffer{}
+	fmt.Fprintf(header, "%s %s; DO NOT EDIT.\n\n", generatedMarker, args)
+	fmt.Fprintf(header, "package %s\n\n", pkg.Name())
+	if len(g.imports) > 0 {
+		paths := make([]string, 0, len(g.imports))
+		for path := range g.imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		fmt.Fprintf(header, "import (\n")
+		for _, path := range paths {
+			if name := g.imports[path]; name != filepath.Base(path) {
+				fmt.Fprintf(header, "%s ", name)
+			}
+			fmt.Fprintf(header, "%q\n", path)
+		}
+		fmt.Fprintf(header, ")\n")
+	}
+
+	src, err := format.Source(append(header.Bytes(), g.buf.Bytes()...))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot format generated code")
+	}
+
+	return src, nil
+}
+
+// loadPackage type checks the package in dir, excluding files generated by shallowgen.
+func loadPackage(dir string) (*types.Package, error) {
+	buildPkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot find package in %q", dir)
+	}
+
+	fset := token.NewFileSet()
+	files := make([]*ast.File, 0, len(buildPkg.GoFiles))
+	for _, name := range buildPkg.GoFiles {
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse %q", name)
+		}
+		if isGenerated(file) {
+			continue
+		}
+		files = append(files, file)
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check(buildPkg.ImportPath, fset, files, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot type check package in %q", dir)
+	}
+
+	return pkg, nil
+}
+
+func isGenerated(file *ast.File) bool {
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if strings.HasPrefix(comment.Text, generatedMarker) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type generator struct {
+	pkg *types.Package
+	tag string
+	// jsonNames is true if fields are named the same way as by encoding/json, see shallow.JSONNames
+	jsonNames bool
+	// collecting contains the structs being collected, to detect anonym struct pointers embedding themselves
+	collecting map[*types.Struct]bool
+	imports    map[string]string
+	buf        bytes.Buffer
+}
+
+// genStruct is the generator's equivalent of the plan compiled by the shallow package for a struct type.
+type genStruct struct {
+	fields []genField
+}
+
+type genField struct {
+	// selector of the field relative to its struct, including the anonym struct fields containing it
+	selector string
+	// name of the field, empty for anonym struct pointer fields
+	name string
+	typ  types.Type
+	// embedded is the struct of anonym struct pointer fields, nil otherwise
+	embedded *genStruct
+}
+
+func (g *generator) generateType(typeName string) error {
+	obj := g.pkg.Scope().Lookup(typeName)
+	if obj == nil {
+		return errors.Errorf("type %q not found in package %q", typeName, g.pkg.Path())
+	}
+	if _, ok := obj.(*types.TypeName); !ok {
+		return errors.Errorf("%q is not a type", typeName)
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return errors.Errorf("type %q is not a struct", typeName)
+	}
+
+	var s *genStruct
+	var err error
+	if g.jsonNames {
+		s, err = g.collectJSON(obj.Type())
+	} else {
+		s, err = g.collect(st, "")
+	}
+	if err != nil {
+		return errors.Wrapf(err, "type %q", typeName)
+	}
+
+	g.printf("// Diff%s compares a and b the same way as shallow.Diff, without reflective field traversal.\n", typeName)
+	g.printf("func Diff%s(a, b *%s, keys map[string]interface{}) []string {\n", typeName, typeName)
+	g.printf("diffKeys := make([]string, 0)\n")
+	g.diffFields(s, "a", "b", 1)
+	g.printf("\nreturn diffKeys\n}\n\n")
+
+	g.printf("// Merge%s merges update into dest the same way as shallow.Merge, without reflective field traversal.\n", typeName)
+	g.printf("func Merge%s(dest, update *%s, keys map[string]interface{}) []string {\n", typeName, typeName)
+	g.printf("updatedKeys := make([]string, 0)\n")
+	g.mergeFields(s, "dest", "update", 1)
+	g.printf("\nreturn updatedKeys\n}\n\n")
+
+	return nil
+}
+
+// collect returns the fields of st the same way as the shallow package compiles its plan.
+func (g *generator) collect(st *types.Struct, prefix string) (*genStruct, error) {
+	g.collecting[st] = true
+	defer delete(g.collecting, st)
+
+	s := &genStruct{}
+	err := g.collectFields(s, st, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (g *generator) collectFields(s *genStruct, st *types.Struct, prefix string) error {
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		selector := prefix + field.Name()
+		if field.Embedded() {
+			if fieldSt, ok := field.Type().Underlying().(*types.Struct); ok {
+				err := g.collectFields(s, fieldSt, selector+".")
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if ptr, ok := field.Type().Underlying().(*types.Pointer); ok {
+				if elemSt, ok := ptr.Elem().Underlying().(*types.Struct); ok {
+					if g.collecting[elemSt] {
+						return errors.Errorf("anonym field %q: anonym struct pointers must not embed themselves", field.Name())
+					}
+					embedded, err := g.collect(elemSt, "")
+					if err != nil {
+						return err
+					}
+					s.fields = append(s.fields, genField{
+						selector: selector,
+						typ:      field.Type(),
+						embedded: embedded,
+					})
+
+					continue
+				}
+			}
+
+			return errors.Errorf("anonym field %q: this method only handles anonym fields of kind struct or pointer to struct", field.Name())
+		}
+
+		tagVal := reflect.StructTag(st.Tag(i)).Get(g.tag)
+		tagVal = strings.SplitN(tagVal, ",", 2)[0]
+		if tagVal == "" {
+			continue
+		}
+		if _, ok := reflect.StructTag(st.Tag(i)).Lookup(shallow.StrategyTag); ok {
+			return errors.Errorf("field %q: %v tags are not supported", field.Name(), shallow.StrategyTag)
+		}
+
+		s.fields = append(s.fields, genField{
+			selector: selector,
+			name:     tagVal,
+			typ:      field.Type(),
+		})
+	}
+
+	return nil
+}
+
+// genJSONField is a field named the same way as by encoding/json, see shallow.JSONNames.
+type genJSONField struct {
+	jsonfield.Field
+	field *types.Var
+	tag   reflect.StructTag
+	// embedded is true for traversed anonym struct pointer fields
+	embedded bool
+}
+
+type genJSONStruct struct {
+	typ   types.Type
+	index []int
+}
+
+// collectJSON returns the fields of the t struct type the same way as the shallow package compiles its plan
+// when fields are named the same way as by encoding/json.
+func (g *generator) collectJSON(t types.Type) (*genStruct, error) {
+	s := &genStruct{}
+	for _, f := range g.jsonFields(t) {
+		err := s.addJSONField(t.Underlying().(*types.Struct), f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// jsonFields returns the fields of the t struct type in index order, resolved the same way as by encoding/json.
+// Traversed anonym struct pointer fields are returned as well.
+func (g *generator) jsonFields(t types.Type) []genJSONField {
+	var fields []genJSONField
+	var embedded []genJSONField
+
+	next := []genJSONStruct{{typ: t}}
+	var count, nextCount map[types.Type]int
+	visited := make(map[types.Type]bool)
+	for len(next) > 0 {
+		current := next
+		next = nil
+		count, nextCount = nextCount, make(map[types.Type]int)
+
+		for _, s := range current {
+			if visited[s.typ] {
+				continue
+			}
+			visited[s.typ] = true
+
+			st := s.typ.Underlying().(*types.Struct)
+			for i := 0; i < st.NumFields(); i++ {
+				field := st.Field(i)
+				ft := field.Type()
+				if ptr, ok := ft.(*types.Pointer); ok {
+					ft = ptr.Elem()
+				}
+				_, isStruct := ft.Underlying().(*types.Struct)
+				if !field.Exported() && (!field.Embedded() || !isStruct) {
+					continue
+				}
+
+				tag := reflect.StructTag(st.Tag(i))
+				tagVal := tag.Get(g.tag)
+				if tagVal == "-" {
+					continue
+				}
+				name := jsonfield.TagName(tagVal)
+
+				index := append(s.index[:len(s.index):len(s.index)], i)
+				if name != "" || !field.Embedded() || !isStruct {
+					f := genJSONField{Field: jsonfield.Field{Name: name, Tagged: name != "", Index: index}, field: field, tag: tag}
+					if name == "" {
+						f.Name = field.Name()
+					}
+					fields = append(fields, f)
+					if count[s.typ] > 1 {
+						fields = append(fields, f)
+					}
+
+					continue
+				}
+
+				if _, ok := field.Type().(*types.Pointer); ok {
+					embedded = append(embedded, genJSONField{Field: jsonfield.Field{Index: index}, field: field, embedded: true})
+				}
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, genJSONStruct{typ: ft, index: index})
+				}
+			}
+		}
+	}
+
+	names := make([]jsonfield.Field, 0, len(fields))
+	for _, f := range fields {
+		names = append(names, f.Field)
+	}
+	dominant := embedded
+	for _, i := range jsonfield.Dominant(names) {
+		dominant = append(dominant, fields[i])
+	}
+	sort.Slice(dominant, func(i, j int) bool {
+		return jsonfield.LessIndex(dominant[i].Index, dominant[j].Index)
+	})
+
+	return dominant
+}
+
+// addJSONField adds the f field of the st struct to s, following the anonym struct pointer fields containing it.
+func (s *genStruct) addJSONField(st *types.Struct, f genJSONField) error {
+	selector := ""
+	for _, i := range f.Index[:len(f.Index)-1] {
+		field := st.Field(i)
+		selector += field.Name()
+		if ptr, ok := field.Type().(*types.Pointer); ok {
+			s = s.embeddedStruct(selector, field.Type())
+			st = ptr.Elem().Underlying().(*types.Struct)
+			selector = ""
+			continue
+		}
+		st = field.Type().Underlying().(*types.Struct)
+		selector += "."
+	}
+	selector += f.field.Name()
+
+	if f.embedded {
+		s.embeddedStruct(selector, f.field.Type())
+		return nil
+	}
+	if _, ok := f.tag.Lookup(shallow.StrategyTag); ok {
+		return errors.Errorf("field %q: %v tags are not supported", f.field.Name(), shallow.StrategyTag)
+	}
+
+	s.fields = append(s.fields, genField{
+		selector: selector,
+		name:     f.Name,
+		typ:      f.field.Type(),
+	})
+
+	return nil
+}
+
+// embeddedStruct returns the struct of the anonym struct pointer field with the given selector. As fields are
+// added in index order, the field is either the last one of s, or it is added now.
+func (s *genStruct) embeddedStruct(selector string, typ types.Type) *genStruct {
+	if n := len(s.fields); n > 0 && s.fields[n-1].embedded != nil && s.fields[n-1].selector == selector {
+		return s.fields[n-1].embedded
+	}
+
+	s.fields = append(s.fields, genField{
+		selector: selector,
+		typ:      typ,
+		embedded: &genStruct{},
+	})
+
+	return s.fields[len(s.fields)-1].embedded
+}
+
+func (g *generator) diffFields(s *genStruct, a string, b string, depth int) {
+	for _, f := range s.fields {
+		if f.embedded != nil {
+			if len(f.embedded.fields) == 0 {
+				continue
+			}
+
+			// a nil first value is compared as a zero value, the same way as it would be allocated by Merge
+			an, bn := fmt.Sprintf("a%d", depth), fmt.Sprintf("b%d", depth)
+			g.printf("\nif %s.%s != nil {\n", b, f.selector)
+			g.printf("%s, %s := %s.%s, %s.%s\n", an, bn, a, f.selector, b, f.selector)
+			g.printf("if %s == nil {\n%s = new(%s)\n}\n", an, an, g.typeString(f.typ.(*types.Pointer).Elem()))
+			g.diffFields(f.embedded, an, bn, depth+1)
+			g.printf("}\n")
+
+			continue
+		}
+
+		g.printf("\nif _, ok := keys[%q]; (ok || keys == nil) && %s {\n", f.name, g.notEqual(f.typ, a+"."+f.selector, b+"."+f.selector))
+		g.printf("diffKeys = append(diffKeys, %q)\n", f.name)
+		g.printf("}\n")
+	}
+}
+
+func (g *generator) mergeFields(s *genStruct, dest string, update string, depth int) {
+	for _, f := range s.fields {
+		if f.embedded != nil {
+			g.printf("\nif %s.%s != nil {\n", update, f.selector)
+			g.printf("if %s.%s == nil {\n%s.%s = new(%s)\n}\n", dest, f.selector, dest, f.selector, g.typeString(f.typ.(*types.Pointer).Elem()))
+			if len(f.embedded.fields) > 0 {
+				dn, un := fmt.Sprintf("d%d", depth), fmt.Sprintf("u%d", depth)
+				g.printf("%s, %s := %s.%s, %s.%s\n", dn, un, dest, f.selector, update, f.selector)
+				g.mergeFields(f.embedded, dn, un, depth+1)
+			}
+			g.printf("}\n")
+
+			continue
+		}
+
+		g.printf("\nif _, ok := keys[%q]; (ok || keys == nil) && %s {\n", f.name, g.notEqual(f.typ, dest+"."+f.selector, update+"."+f.selector))
+		g.printf("updatedKeys = append(updatedKeys, %q)\n", f.name)
+		g.printf("%s.%s = %s.%s\n", dest, f.selector, update, f.selector)
+		g.printf("}\n")
+	}
+}
+
+// notEqual returns an expression reporting whether the a and b values of type t differ, the same way as the shallow
+// package compares them: with their Equal method if they have one, or with reflect.DeepEqual.
+func (g *generator) notEqual(t types.Type, a string, b string) string {
+	if hasEqualMethod(t) {
+		if _, ok := t.Underlying().(*types.Pointer); ok {
+			return fmt.Sprintf("!(%s == nil && %s == nil || %s != nil && %s != nil && %s.Equal(%s))", a, b, a, b, a, b)
+		}
+
+		return fmt.Sprintf("!%s.Equal(%s)", a, b)
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok && hasEqualMethod(ptr.Elem()) {
+		return fmt.Sprintf("!(%s == nil && %s == nil || %s != nil && %s != nil && %s.Equal(*%s))", a, b, a, b, a, b)
+	}
+
+	if basic, ok := t.Underlying().(*types.Basic); ok && basic.Info()&(types.IsBoolean|types.IsNumeric|types.IsString) != 0 {
+		return fmt.Sprintf("%s != %s", a, b)
+	}
+
+	g.imports["reflect"] = "reflect"
+
+	return fmt.Sprintf("!reflect.DeepEqual(%s, %s)", a, b)
+}
+
+// hasEqualMethod checks if t has a method with the signature func (T) Equal(T) bool.
+func hasEqualMethod(t types.Type) bool {
+	if types.IsInterface(t) {
+		return false
+	}
+
+	obj, _, _ := types.LookupFieldOrMethod(t, false, nil, "Equal")
+	method, ok := obj.(*types.Func)
+	if !ok || !method.Exported() {
+		return false
+	}
+	sig := method.Type().(*types.Signature)
+	if sig.Params().Len() != 1 || sig.Results().Len() != 1 || sig.Variadic() {
+		return false
+	}
+	result, ok := sig.Results().At(0).Type().Underlying().(*types.Basic)
+
+	return types.Identical(sig.Params().At(0).Type(), t) && ok && result.Kind() == types.Bool
+}
+
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		g.imports[p.Path()] = p.Name()
+
+		return p.Name()
+	})
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
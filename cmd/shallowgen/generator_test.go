@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+// TestGenerateUpToDate makes sure that the code checked by the gentest package is the one produced by the generator.
+func TestGenerateUpToDate(t *testing.T) {
+	got, err := generate("../../internal/gentest", []string{"Test"}, "json", "-type=Test")
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	want, err := os.ReadFile("../../internal/gentest/test_shallow.go")
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	if diff := pretty.Diff(string(want), string(got)); len(diff) > 0 {
+		t.Errorf("generated code is out of date, run go generate ./...: diffs (want/got): %v", diff)
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	for name, data := range map[string]struct {
+		typeName string
+		tag      string
+	}{
+		"not_found":          {typeName: "NotFound", tag: "json"},
+		"not_struct":         {typeName: "NotStruct", tag: "json"},
+		"unsupported_anonym": {typeName: "WithUnsupportedAnonym", tag: "patch"},
+		"strategy":           {typeName: "WithStrategy", tag: "json"},
+		"recursive_embedded": {typeName: "Node", tag: "patch"},
+	} {
+		_, err := generate("testdata/invalid", []string{data.typeName}, data.tag, "-type="+data.typeName)
+		if err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Shallowgen generates equivalents of shallow.Diff and shallow.Merge for struct types, which access the fields
+// directly instead of traversing them with reflection. Fields are compared with their Equal method, with == for
+// basic types, and with reflect.DeepEqual otherwise.
+//
+// For every given type T, it generates the following functions:
+//
+//	func DiffT(a, b *T, keys map[string]interface{}) []string
+//	func MergeT(dest, update *T, keys map[string]interface{}) []string
+//
+// with the same semantics as shallow.Diff and shallow.Merge called without options (other than the tag).
+//
+// Usage:
+//
+//	//go:generate go run github.com/proemergotech/shallow/cmd/shallowgen -type=T
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("shallowgen: ")
+
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; must be set")
+	tag := flag.String("tag", "json", "struct tag used to resolve keys")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_shallow.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: shallowgen -type=T [-tag=json] [-output=file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	src, err := generate(dir, types, *tag, strings.Join(os.Args[1:], " "))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	outputName := *output
+	if outputName == "" {
+		outputName = filepath.Join(dir, strings.ToLower(types[0])+"_shallow.go")
+	}
+	err = os.WriteFile(outputName, src, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,19 @@
+package invalid
+
+type NotStruct string
+
+type Str string
+
+type WithUnsupportedAnonym struct {
+	String string `json:"string"`
+	Str
+}
+
+type WithStrategy struct {
+	Strings []string `json:"strings" shallow:"append"`
+}
+
+type Node struct {
+	*Node
+	Name string `patch:"name"`
+}
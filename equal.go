@@ -0,0 +1,107 @@
+package shallow
+
+import (
+	"reflect"
+)
+
+// WithComparator can be used to compare fields of type t (or pointers to t) with the given function,
+// instead of reflect.DeepEqual. The function is called with values of type t.
+//
+// Fields are compared with the first of the following which applies to their type:
+//   - a comparator registered with the WithComparator option,
+//   - the Equal method of the type, if it has the signature func (T) Equal(T) bool (e.g. time.Time),
+//     for pointer types, the Equal method of the element type is used for non-nil values,
+//   - reflect.DeepEqual.
+//
+// Nested struct fields with a comparator or an Equal method are compared as a whole even with the Deep option.
+func WithComparator(t reflect.Type, equal func(a interface{}, b interface{}) bool) Option {
+	return func(o *options) {
+		if o.comparators == nil {
+			o.comparators = make(map[reflect.Type]func(a interface{}, b interface{}) bool)
+		}
+		o.comparators[t] = equal
+	}
+}
+
+// equalFunc compares two values of the same type.
+type equalFunc func(a reflect.Value, b reflect.Value) bool
+
+// equal compares the a and b values of the same type. The eq function is the Equal method of the type (if any),
+// as returned by equalMethod.
+func (o *options) equal(a reflect.Value, b reflect.Value, eq equalFunc) bool {
+	if cmp := o.comparator(a.Type()); cmp != nil {
+		return cmp(a, b)
+	}
+	if eq != nil {
+		return eq(a, b)
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// comparator returns the comparator registered for t, or for the element type of t if it is a pointer.
+func (o *options) comparator(t reflect.Type) equalFunc {
+	if len(o.comparators) == 0 {
+		return nil
+	}
+
+	if cmp, ok := o.comparators[t]; ok {
+		return func(a reflect.Value, b reflect.Value) bool {
+			return cmp(a.Interface(), b.Interface())
+		}
+	}
+	if t.Kind() == reflect.Ptr {
+		if cmp, ok := o.comparators[t.Elem()]; ok {
+			return nilSafe(func(a reflect.Value, b reflect.Value) bool {
+				return cmp(a.Elem().Interface(), b.Elem().Interface())
+			})
+		}
+	}
+
+	return nil
+}
+
+// equalMethod returns a function calling the Equal method of t, or of the element type of t if it is a pointer.
+// Returns nil if there is no such method.
+func equalMethod(t reflect.Type) equalFunc {
+	if t.Kind() == reflect.Interface {
+		return nil
+	}
+
+	if m, ok := t.MethodByName("Equal"); ok && isEqualMethod(m.Type, t) {
+		eq := func(a reflect.Value, b reflect.Value) bool {
+			return a.Method(m.Index).Call([]reflect.Value{b})[0].Bool()
+		}
+		if t.Kind() == reflect.Ptr {
+			return nilSafe(eq)
+		}
+
+		return eq
+	}
+
+	if t.Kind() == reflect.Ptr {
+		if m, ok := t.Elem().MethodByName("Equal"); ok && isEqualMethod(m.Type, t.Elem()) {
+			return nilSafe(func(a reflect.Value, b reflect.Value) bool {
+				return a.Elem().Method(m.Index).Call([]reflect.Value{b.Elem()})[0].Bool()
+			})
+		}
+	}
+
+	return nil
+}
+
+// isEqualMethod checks if the method type (including the receiver) is func(T, T) bool.
+func isEqualMethod(methodType reflect.Type, t reflect.Type) bool {
+	return methodType.NumIn() == 2 && methodType.In(1) == t && methodType.NumOut() == 1 && methodType.Out(0).Kind() == reflect.Bool
+}
+
+// nilSafe wraps an equal function of pointers, so that it is only called if both pointers are non-nil.
+func nilSafe(eq equalFunc) equalFunc {
+	return func(a reflect.Value, b reflect.Value) bool {
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() && b.IsNil()
+		}
+
+		return eq(a, b)
+	}
+}
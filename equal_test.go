@@ -0,0 +1,123 @@
+package shallow
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+type caseInsensitive string
+
+type equalTest struct {
+	Time    time.Time        `json:"time"`
+	TimePtr *time.Time       `json:"time_ptr"`
+	Version *version         `json:"version"`
+	Name    caseInsensitive  `json:"name"`
+	NamePtr *caseInsensitive `json:"name_ptr"`
+}
+
+type version struct {
+	Major int `json:"major"`
+	Minor int `json:"minor"`
+}
+
+// Equal ignores the minor version.
+func (v *version) Equal(other *version) bool {
+	return v.Major == other.Major
+}
+
+func TestEqual(t *testing.T) {
+	utc := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	local := utc.In(time.FixedZone("UTC+1", 60*60))
+	later := utc.Add(time.Second)
+	name := caseInsensitive("name")
+	upperName := caseInsensitive("NAME")
+	ignoreCase := WithComparator(reflect.TypeOf(caseInsensitive("")), func(a interface{}, b interface{}) bool {
+		return strings.EqualFold(string(a.(caseInsensitive)), string(b.(caseInsensitive)))
+	})
+
+	for name, data := range map[string]struct {
+		first           equalTest
+		second          equalTest
+		opts            []Option
+		wantChangedKeys []string
+	}{
+		"time_same_instant": {
+			first:           equalTest{Time: utc, TimePtr: &utc},
+			second:          equalTest{Time: local, TimePtr: &local},
+			wantChangedKeys: []string{},
+		},
+		"time_changed": {
+			first:           equalTest{Time: utc, TimePtr: &utc},
+			second:          equalTest{Time: later, TimePtr: &later},
+			wantChangedKeys: []string{"time", "time_ptr"},
+		},
+		"time_ptr_nil": {
+			first:           equalTest{TimePtr: &utc},
+			second:          equalTest{},
+			wantChangedKeys: []string{"time_ptr"},
+		},
+		"pointer_receiver": {
+			first:           equalTest{Version: &version{Major: 1, Minor: 1}},
+			second:          equalTest{Version: &version{Major: 1, Minor: 2}},
+			wantChangedKeys: []string{},
+		},
+		"pointer_receiver_changed": {
+			first:           equalTest{Version: &version{Major: 1, Minor: 1}},
+			second:          equalTest{Version: &version{Major: 2, Minor: 1}},
+			wantChangedKeys: []string{"version"},
+		},
+		"pointer_receiver_deep": {
+			first:           equalTest{Version: &version{Major: 1, Minor: 1}},
+			second:          equalTest{Version: &version{Major: 1, Minor: 2}},
+			opts:            []Option{Deep()},
+			wantChangedKeys: []string{},
+		},
+		"pointer_receiver_nil": {
+			first:           equalTest{Version: &version{Major: 1, Minor: 1}},
+			second:          equalTest{},
+			wantChangedKeys: []string{"version"},
+		},
+		"without_comparator": {
+			first:           equalTest{Name: "name", NamePtr: &name},
+			second:          equalTest{Name: "NAME", NamePtr: &upperName},
+			wantChangedKeys: []string{"name", "name_ptr"},
+		},
+		"comparator": {
+			first:           equalTest{Name: "name", NamePtr: &name},
+			second:          equalTest{Name: "NAME", NamePtr: &upperName},
+			opts:            []Option{ignoreCase},
+			wantChangedKeys: []string{},
+		},
+		"comparator_nil": {
+			first:           equalTest{Name: "name", NamePtr: &name},
+			second:          equalTest{Name: "NAME"},
+			opts:            []Option{ignoreCase},
+			wantChangedKeys: []string{"name_ptr"},
+		},
+	} {
+		gotDiffKeys, err := Diff(&data.first, &data.second, nil, data.opts...)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotDiffKeys); len(diff) > 0 {
+			t.Errorf("%v diffKeys: diffs (want/got): %v", name, diff)
+		}
+
+		orig := data.first
+		gotChangedKeys, err := Merge(&orig, &data.second, nil, data.opts...)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, diff)
+		}
+		if len(gotChangedKeys) == 0 && !reflect.DeepEqual(data.first, orig) {
+			t.Errorf("%v: no-op merge modified dest", name)
+		}
+	}
+}
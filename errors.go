@@ -0,0 +1,85 @@
+package shallow
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/proemergotech/errors/v2"
+)
+
+// Errors wrapped by *TypeError, which can be checked with errors.Is.
+var (
+	// ErrNotStructPointer means that an argument is not a pointer to a struct.
+	ErrNotStructPointer = errors.New("not a pointer to a struct")
+	// ErrTypeMismatch means that an argument does not have the same type as dest (or first).
+	ErrTypeMismatch = errors.New("type mismatch")
+	// ErrNilPointer means that an argument is a nil pointer.
+	ErrNilPointer = errors.New("nil pointer")
+	// ErrUnsupportedEmbedded means that a struct has an anonym field which is not a struct or a pointer to a struct.
+	ErrUnsupportedEmbedded = errors.New("anonym fields must be of kind struct or pointer to struct")
+	// ErrRecursiveEmbedded means that a struct embeds a pointer to itself, directly or through other anonym fields.
+	ErrRecursiveEmbedded = errors.New("anonym struct pointers must not embed themselves")
+	// ErrUnexportedEmbedded means that a nil anonym pointer field to an unexported struct type would have to be
+	// allocated by a merge, which is not possible with reflection.
+	ErrUnexportedEmbedded = errors.New("cannot allocate anonym pointer to unexported struct")
+)
+
+// TypeError is returned if the arguments or their types are not supported.
+type TypeError struct {
+	// Err is one of ErrNotStructPointer, ErrTypeMismatch, ErrNilPointer, ErrUnsupportedEmbedded,
+	// ErrRecursiveEmbedded and ErrUnexportedEmbedded.
+	Err error
+	// Type is the type of the argument, or the struct type containing the field.
+	Type reflect.Type
+	// Expected is the type of dest (or first), for ErrTypeMismatch.
+	Expected reflect.Type
+	// Field is the path of the field within Type, as Go field names separated by dots (e.g. "Anonym.Field"), for
+	// ErrUnsupportedEmbedded, ErrRecursiveEmbedded and ErrUnexportedEmbedded.
+	Field string
+}
+
+func (e *TypeError) Error() string {
+	switch {
+	case e.Field != "":
+		return fmt.Sprintf("%v: field %v of %v", e.Err, e.Field, e.Type)
+	case e.Expected != nil:
+		return fmt.Sprintf("%v: %v, expected %v", e.Err, e.Type, e.Expected)
+	default:
+		return fmt.Sprintf("%v: %v", e.Err, e.Type)
+	}
+}
+
+func (e *TypeError) Unwrap() error {
+	return e.Err
+}
+
+// checkStructPointer checks that v is a non-nil pointer to a struct.
+func checkStructPointer(v interface{}) error {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return &TypeError{Err: ErrNotStructPointer, Type: t}
+	}
+	if reflect.ValueOf(v).IsNil() {
+		return &TypeError{Err: ErrNilPointer, Type: t}
+	}
+
+	return nil
+}
+
+// checkSameType checks that target is a non-nil pointer to a struct, and v is a non-nil pointer of the same type.
+func checkSameType(target interface{}, v interface{}) error {
+	err := checkStructPointer(target)
+	if err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(v)
+	if t != reflect.TypeOf(target) {
+		return &TypeError{Err: ErrTypeMismatch, Type: t, Expected: reflect.TypeOf(target)}
+	}
+	if reflect.ValueOf(v).IsNil() {
+		return &TypeError{Err: ErrNilPointer, Type: t}
+	}
+
+	return nil
+}
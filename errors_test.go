@@ -0,0 +1,129 @@
+package shallow
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTypeError(t *testing.T) {
+	type Str string
+	type testWithStr struct {
+		Str
+	}
+	type testWithNested struct {
+		*testWithStr
+	}
+	type Node struct {
+		*Node
+		Name string `json:"name"`
+	}
+	type unexported struct {
+		Name string `json:"name"`
+	}
+	type withUnexported struct {
+		*unexported
+	}
+
+	var nilTest *test
+	for name, data := range map[string]struct {
+		call      func() error
+		wantErr   error
+		wantType  reflect.Type
+		wantField string
+	}{
+		"not_pointer": {
+			call: func() error {
+				_, err := Diff(test{}, test{}, nil)
+				return err
+			},
+			wantErr:  ErrNotStructPointer,
+			wantType: reflect.TypeOf(test{}),
+		},
+		"nil_interface": {
+			call: func() error {
+				_, err := MergePatchJSON(nil, []byte(`{}`))
+				return err
+			},
+			wantErr: ErrNotStructPointer,
+		},
+		"type_mismatch": {
+			call: func() error {
+				_, err := Merge(&test{}, &Nested{}, nil)
+				return err
+			},
+			wantErr:  ErrTypeMismatch,
+			wantType: reflect.TypeOf(&Nested{}),
+		},
+		"nil_pointer": {
+			call: func() error {
+				_, err := Merge(&test{}, nilTest, nil)
+				return err
+			},
+			wantErr:  ErrNilPointer,
+			wantType: reflect.TypeOf(nilTest),
+		},
+		"nil_dest": {
+			call: func() error {
+				_, err := MergeMap(nilTest, nil)
+				return err
+			},
+			wantErr:  ErrNilPointer,
+			wantType: reflect.TypeOf(nilTest),
+		},
+		"unsupported_embedded": {
+			call: func() error {
+				_, err := Diff(&testWithStr{}, &testWithStr{}, nil, JSONNames(false))
+				return err
+			},
+			wantErr:   ErrUnsupportedEmbedded,
+			wantType:  reflect.TypeOf(testWithStr{}),
+			wantField: "Str",
+		},
+		"unsupported_embedded_nested": {
+			call: func() error {
+				_, err := Diff(&testWithNested{}, &testWithNested{}, nil, JSONNames(false))
+				return err
+			},
+			wantErr:   ErrUnsupportedEmbedded,
+			wantType:  reflect.TypeOf(testWithNested{}),
+			wantField: "testWithStr.Str",
+		},
+		"recursive_embedded": {
+			call: func() error {
+				_, err := Merge(&Node{}, &Node{Name: "name"}, nil, JSONNames(false))
+				return err
+			},
+			wantErr:   ErrRecursiveEmbedded,
+			wantType:  reflect.TypeOf(Node{}),
+			wantField: "Node",
+		},
+		"unexported_embedded": {
+			call: func() error {
+				_, err := Merge(&withUnexported{}, &withUnexported{unexported: &unexported{Name: "name"}}, nil)
+				return err
+			},
+			wantErr:   ErrUnexportedEmbedded,
+			wantType:  reflect.TypeOf(withUnexported{}),
+			wantField: "unexported",
+		},
+	} {
+		err := data.call()
+		if !errors.Is(err, data.wantErr) {
+			t.Errorf("%v: expected %v, got: %v", name, data.wantErr, err)
+			continue
+		}
+
+		var typeErr *TypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("%v: expected *TypeError, got: %T", name, err)
+			continue
+		}
+		if typeErr.Type != data.wantType {
+			t.Errorf("%v: expected type %v, got: %v", name, data.wantType, typeErr.Type)
+		}
+		if typeErr.Field != data.wantField {
+			t.Errorf("%v: expected field %v, got: %v", name, data.wantField, typeErr.Field)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/proemergotech/shallow
 
-go 1.17
+go 1.18
 
 require (
 	github.com/kr/pretty v0.1.0
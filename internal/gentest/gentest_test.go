@@ -0,0 +1,205 @@
+package gentest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+	"github.com/proemergotech/shallow"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+func stringPtr(str string) *string {
+	return &str
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func testData(modify func(Test) Test) Test {
+	data := Test{
+		String:    "string_val",
+		StringPtr: stringPtr("string_ptr_val"),
+		Bool:      true,
+		BoolPtr:   boolPtr(true),
+		Float:     1.5,
+		Status:    "active",
+		Strings:   []string{"a", "b"},
+		Labels:    map[string]string{"env": "prod"},
+		Time:      time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		TimePtr:   timePtr(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Nested: Nested{
+			String:    "nested_string_val",
+			StringPtr: stringPtr("nested_string_ptr_val"),
+			Bool:      true,
+			BoolPtr:   boolPtr(true),
+		},
+		NestedPtr: &Nested{
+			String:    "nested_ptr_string_val",
+			StringPtr: stringPtr("nested_ptr_string_ptr_val"),
+		},
+		Untagged: "untagged_val",
+		Ignored:  "ignored_val",
+		Anonym: Anonym{
+			AnonymString:    "anonym_string_val",
+			AnonymStringPtr: stringPtr("anonym_string_ptr_val"),
+			AnonymNested:    Nested{String: "nested_string_val"},
+			AnonymNestedPtr: &Nested{String: "nested_ptr_string_val"},
+		},
+		AnonymPtr: &AnonymPtr{
+			AnonymPtrString:    "anonym_ptr_string_val",
+			AnonymPtrStringPtr: stringPtr("anonym_ptr_string_ptr_val"),
+			AnonymPtrNested:    Nested{String: "nested_string_val"},
+			AnonymPtr2: &AnonymPtr2{
+				AnonymPtr2String:    "anonym_ptr2_string_val",
+				AnonymPtr2BoolPtr:   boolPtr(true),
+				AnonymPtr2NestedPtr: &Nested{String: "nested_ptr_string_val"},
+			},
+		},
+		Tagged: Tagged{String: "tagged_string_val"},
+		Label:  "label_val",
+	}
+
+	if modify != nil {
+		data = modify(data)
+	}
+
+	return data
+}
+
+// TestGenerated checks that the generated functions behave exactly like their reflective counterparts.
+func TestGenerated(t *testing.T) {
+	allChanged := func(t Test) Test {
+		return Test{
+			String:    "test2",
+			BoolPtr:   boolPtr(false),
+			Float:     2.5,
+			Status:    "inactive",
+			Strings:   []string{"c"},
+			Time:      time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+			Nested:    Nested{String: "test2"},
+			NestedPtr: &Nested{Bool: true},
+			Untagged:  "test2",
+			Ignored:   "test2",
+			Anonym: Anonym{
+				AnonymString: "test2",
+				AnonymNested: Nested{Bool: true},
+			},
+			AnonymPtr: &AnonymPtr{
+				AnonymPtrStringPtr: stringPtr("test2"),
+				AnonymPtr2: &AnonymPtr2{
+					AnonymPtr2String: "test2",
+				},
+				Empty: &Empty{Untagged: "test2"},
+			},
+			Tagged: Tagged{String: "test2"},
+			Label:  "test2",
+		}
+	}
+
+	for name, data := range map[string]struct {
+		current func() Test
+		update  Test
+		keys    map[string]interface{}
+	}{
+		"same": {
+			current: func() Test { return testData(nil) },
+			update:  testData(nil),
+		},
+		"all_changed": {
+			current: func() Test { return testData(nil) },
+			update:  testData(allChanged),
+		},
+		"all_changed_keys": {
+			current: func() Test { return testData(nil) },
+			update:  testData(allChanged),
+			keys: map[string]interface{}{
+				"string":                nil,
+				"float":                 nil,
+				"labels":                nil,
+				"nested_ptr":            nil,
+				"anonym_nested":         nil,
+				"anonym_ptr_string":     nil,
+				"anonym_ptr_string_ptr": nil,
+				"anonym_ptr2_string":    nil,
+				"Untagged":              nil,
+				"tagged":                nil,
+				"Ignored":               nil,
+				"unknown":               nil,
+			},
+		},
+		"empty_keys": {
+			current: func() Test { return testData(nil) },
+			update:  testData(allChanged),
+			keys:    map[string]interface{}{},
+		},
+		"time_location": {
+			current: func() Test { return testData(nil) },
+			update: testData(func(t Test) Test {
+				t.Time = t.Time.In(time.FixedZone("UTC+1", 60*60))
+				t.TimePtr = timePtr(t.TimePtr.In(time.FixedZone("UTC+1", 60*60)))
+				return t
+			}),
+		},
+		"nil_values": {
+			current: func() Test { return testData(nil) },
+			update:  Test{},
+		},
+		"nil_current": {
+			current: func() Test { return Test{} },
+			update:  testData(nil),
+		},
+		"nil_anonym_ptr2": {
+			current: func() Test {
+				return testData(func(t Test) Test {
+					t.AnonymPtr2 = nil
+					return t
+				})
+			},
+			update: testData(allChanged),
+			keys: map[string]interface{}{
+				"anonym_ptr2_bool_ptr": nil,
+			},
+		},
+		"nil_anonym_ptr2_same": {
+			current: func() Test {
+				return testData(func(t Test) Test {
+					t.AnonymPtr2 = nil
+					return t
+				})
+			},
+			update: testData(func(t Test) Test {
+				t.AnonymPtr2 = &AnonymPtr2{}
+				return t
+			}),
+		},
+	} {
+		current := data.current()
+		gotDiffKeys := DiffTest(&current, &data.update, data.keys)
+		wantDiffKeys, err := shallow.Diff(&current, &data.update, data.keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(wantDiffKeys, gotDiffKeys); len(diff) > 0 {
+			t.Errorf("%v diffKeys: diffs (want/got): %v", name, diff)
+		}
+
+		got := data.current()
+		gotUpdatedKeys := MergeTest(&got, &data.update, data.keys)
+		want := data.current()
+		wantUpdatedKeys, err := shallow.Merge(&want, &data.update, data.keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(wantUpdatedKeys, gotUpdatedKeys); len(diff) > 0 {
+			t.Errorf("%v updatedKeys: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+	}
+}
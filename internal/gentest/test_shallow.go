@@ -0,0 +1,280 @@
+// Code generated by shallowgen -type=Test; DO NOT EDIT.
+
+package gentest
+
+import (
+	"reflect"
+)
+
+// DiffTest compares a and b the same way as shallow.Diff, without reflective field traversal.
+func DiffTest(a, b *Test, keys map[string]interface{}) []string {
+	diffKeys := make([]string, 0)
+
+	if _, ok := keys["string"]; (ok || keys == nil) && a.String != b.String {
+		diffKeys = append(diffKeys, "string")
+	}
+
+	if _, ok := keys["string_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(a.StringPtr, b.StringPtr) {
+		diffKeys = append(diffKeys, "string_ptr")
+	}
+
+	if _, ok := keys["bool"]; (ok || keys == nil) && a.Bool != b.Bool {
+		diffKeys = append(diffKeys, "bool")
+	}
+
+	if _, ok := keys["bool_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(a.BoolPtr, b.BoolPtr) {
+		diffKeys = append(diffKeys, "bool_ptr")
+	}
+
+	if _, ok := keys["float"]; (ok || keys == nil) && a.Float != b.Float {
+		diffKeys = append(diffKeys, "float")
+	}
+
+	if _, ok := keys["status"]; (ok || keys == nil) && a.Status != b.Status {
+		diffKeys = append(diffKeys, "status")
+	}
+
+	if _, ok := keys["strings"]; (ok || keys == nil) && !reflect.DeepEqual(a.Strings, b.Strings) {
+		diffKeys = append(diffKeys, "strings")
+	}
+
+	if _, ok := keys["labels"]; (ok || keys == nil) && !reflect.DeepEqual(a.Labels, b.Labels) {
+		diffKeys = append(diffKeys, "labels")
+	}
+
+	if _, ok := keys["time"]; (ok || keys == nil) && !a.Time.Equal(b.Time) {
+		diffKeys = append(diffKeys, "time")
+	}
+
+	if _, ok := keys["time_ptr"]; (ok || keys == nil) && !(a.TimePtr == nil && b.TimePtr == nil || a.TimePtr != nil && b.TimePtr != nil && a.TimePtr.Equal(*b.TimePtr)) {
+		diffKeys = append(diffKeys, "time_ptr")
+	}
+
+	if _, ok := keys["nested"]; (ok || keys == nil) && !reflect.DeepEqual(a.Nested, b.Nested) {
+		diffKeys = append(diffKeys, "nested")
+	}
+
+	if _, ok := keys["nested_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(a.NestedPtr, b.NestedPtr) {
+		diffKeys = append(diffKeys, "nested_ptr")
+	}
+
+	if _, ok := keys["Untagged"]; (ok || keys == nil) && a.Untagged != b.Untagged {
+		diffKeys = append(diffKeys, "Untagged")
+	}
+
+	if _, ok := keys["anonym_string"]; (ok || keys == nil) && a.Anonym.AnonymString != b.Anonym.AnonymString {
+		diffKeys = append(diffKeys, "anonym_string")
+	}
+
+	if _, ok := keys["anonym_string_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(a.Anonym.AnonymStringPtr, b.Anonym.AnonymStringPtr) {
+		diffKeys = append(diffKeys, "anonym_string_ptr")
+	}
+
+	if _, ok := keys["anonym_nested"]; (ok || keys == nil) && !reflect.DeepEqual(a.Anonym.AnonymNested, b.Anonym.AnonymNested) {
+		diffKeys = append(diffKeys, "anonym_nested")
+	}
+
+	if _, ok := keys["anonym_nested_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(a.Anonym.AnonymNestedPtr, b.Anonym.AnonymNestedPtr) {
+		diffKeys = append(diffKeys, "anonym_nested_ptr")
+	}
+
+	if b.AnonymPtr != nil {
+		a1, b1 := a.AnonymPtr, b.AnonymPtr
+		if a1 == nil {
+			a1 = new(AnonymPtr)
+		}
+
+		if _, ok := keys["anonym_ptr_string"]; (ok || keys == nil) && a1.AnonymPtrString != b1.AnonymPtrString {
+			diffKeys = append(diffKeys, "anonym_ptr_string")
+		}
+
+		if _, ok := keys["anonym_ptr_string_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(a1.AnonymPtrStringPtr, b1.AnonymPtrStringPtr) {
+			diffKeys = append(diffKeys, "anonym_ptr_string_ptr")
+		}
+
+		if _, ok := keys["anonym_ptr_nested"]; (ok || keys == nil) && !reflect.DeepEqual(a1.AnonymPtrNested, b1.AnonymPtrNested) {
+			diffKeys = append(diffKeys, "anonym_ptr_nested")
+		}
+
+		if b1.AnonymPtr2 != nil {
+			a2, b2 := a1.AnonymPtr2, b1.AnonymPtr2
+			if a2 == nil {
+				a2 = new(AnonymPtr2)
+			}
+
+			if _, ok := keys["anonym_ptr2_string"]; (ok || keys == nil) && a2.AnonymPtr2String != b2.AnonymPtr2String {
+				diffKeys = append(diffKeys, "anonym_ptr2_string")
+			}
+
+			if _, ok := keys["anonym_ptr2_bool_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(a2.AnonymPtr2BoolPtr, b2.AnonymPtr2BoolPtr) {
+				diffKeys = append(diffKeys, "anonym_ptr2_bool_ptr")
+			}
+
+			if _, ok := keys["anonym_ptr2_nested_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(a2.AnonymPtr2NestedPtr, b2.AnonymPtr2NestedPtr) {
+				diffKeys = append(diffKeys, "anonym_ptr2_nested_ptr")
+			}
+		}
+	}
+
+	if _, ok := keys["tagged"]; (ok || keys == nil) && !reflect.DeepEqual(a.Tagged, b.Tagged) {
+		diffKeys = append(diffKeys, "tagged")
+	}
+
+	if _, ok := keys["Label"]; (ok || keys == nil) && a.Label != b.Label {
+		diffKeys = append(diffKeys, "Label")
+	}
+
+	return diffKeys
+}
+
+// MergeTest merges update into dest the same way as shallow.Merge, without reflective field traversal.
+func MergeTest(dest, update *Test, keys map[string]interface{}) []string {
+	updatedKeys := make([]string, 0)
+
+	if _, ok := keys["string"]; (ok || keys == nil) && dest.String != update.String {
+		updatedKeys = append(updatedKeys, "string")
+		dest.String = update.String
+	}
+
+	if _, ok := keys["string_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(dest.StringPtr, update.StringPtr) {
+		updatedKeys = append(updatedKeys, "string_ptr")
+		dest.StringPtr = update.StringPtr
+	}
+
+	if _, ok := keys["bool"]; (ok || keys == nil) && dest.Bool != update.Bool {
+		updatedKeys = append(updatedKeys, "bool")
+		dest.Bool = update.Bool
+	}
+
+	if _, ok := keys["bool_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(dest.BoolPtr, update.BoolPtr) {
+		updatedKeys = append(updatedKeys, "bool_ptr")
+		dest.BoolPtr = update.BoolPtr
+	}
+
+	if _, ok := keys["float"]; (ok || keys == nil) && dest.Float != update.Float {
+		updatedKeys = append(updatedKeys, "float")
+		dest.Float = update.Float
+	}
+
+	if _, ok := keys["status"]; (ok || keys == nil) && dest.Status != update.Status {
+		updatedKeys = append(updatedKeys, "status")
+		dest.Status = update.Status
+	}
+
+	if _, ok := keys["strings"]; (ok || keys == nil) && !reflect.DeepEqual(dest.Strings, update.Strings) {
+		updatedKeys = append(updatedKeys, "strings")
+		dest.Strings = update.Strings
+	}
+
+	if _, ok := keys["labels"]; (ok || keys == nil) && !reflect.DeepEqual(dest.Labels, update.Labels) {
+		updatedKeys = append(updatedKeys, "labels")
+		dest.Labels = update.Labels
+	}
+
+	if _, ok := keys["time"]; (ok || keys == nil) && !dest.Time.Equal(update.Time) {
+		updatedKeys = append(updatedKeys, "time")
+		dest.Time = update.Time
+	}
+
+	if _, ok := keys["time_ptr"]; (ok || keys == nil) && !(dest.TimePtr == nil && update.TimePtr == nil || dest.TimePtr != nil && update.TimePtr != nil && dest.TimePtr.Equal(*update.TimePtr)) {
+		updatedKeys = append(updatedKeys, "time_ptr")
+		dest.TimePtr = update.TimePtr
+	}
+
+	if _, ok := keys["nested"]; (ok || keys == nil) && !reflect.DeepEqual(dest.Nested, update.Nested) {
+		updatedKeys = append(updatedKeys, "nested")
+		dest.Nested = update.Nested
+	}
+
+	if _, ok := keys["nested_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(dest.NestedPtr, update.NestedPtr) {
+		updatedKeys = append(updatedKeys, "nested_ptr")
+		dest.NestedPtr = update.NestedPtr
+	}
+
+	if _, ok := keys["Untagged"]; (ok || keys == nil) && dest.Untagged != update.Untagged {
+		updatedKeys = append(updatedKeys, "Untagged")
+		dest.Untagged = update.Untagged
+	}
+
+	if _, ok := keys["anonym_string"]; (ok || keys == nil) && dest.Anonym.AnonymString != update.Anonym.AnonymString {
+		updatedKeys = append(updatedKeys, "anonym_string")
+		dest.Anonym.AnonymString = update.Anonym.AnonymString
+	}
+
+	if _, ok := keys["anonym_string_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(dest.Anonym.AnonymStringPtr, update.Anonym.AnonymStringPtr) {
+		updatedKeys = append(updatedKeys, "anonym_string_ptr")
+		dest.Anonym.AnonymStringPtr = update.Anonym.AnonymStringPtr
+	}
+
+	if _, ok := keys["anonym_nested"]; (ok || keys == nil) && !reflect.DeepEqual(dest.Anonym.AnonymNested, update.Anonym.AnonymNested) {
+		updatedKeys = append(updatedKeys, "anonym_nested")
+		dest.Anonym.AnonymNested = update.Anonym.AnonymNested
+	}
+
+	if _, ok := keys["anonym_nested_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(dest.Anonym.AnonymNestedPtr, update.Anonym.AnonymNestedPtr) {
+		updatedKeys = append(updatedKeys, "anonym_nested_ptr")
+		dest.Anonym.AnonymNestedPtr = update.Anonym.AnonymNestedPtr
+	}
+
+	if update.AnonymPtr != nil {
+		if dest.AnonymPtr == nil {
+			dest.AnonymPtr = new(AnonymPtr)
+		}
+		d1, u1 := dest.AnonymPtr, update.AnonymPtr
+
+		if _, ok := keys["anonym_ptr_string"]; (ok || keys == nil) && d1.AnonymPtrString != u1.AnonymPtrString {
+			updatedKeys = append(updatedKeys, "anonym_ptr_string")
+			d1.AnonymPtrString = u1.AnonymPtrString
+		}
+
+		if _, ok := keys["anonym_ptr_string_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(d1.AnonymPtrStringPtr, u1.AnonymPtrStringPtr) {
+			updatedKeys = append(updatedKeys, "anonym_ptr_string_ptr")
+			d1.AnonymPtrStringPtr = u1.AnonymPtrStringPtr
+		}
+
+		if _, ok := keys["anonym_ptr_nested"]; (ok || keys == nil) && !reflect.DeepEqual(d1.AnonymPtrNested, u1.AnonymPtrNested) {
+			updatedKeys = append(updatedKeys, "anonym_ptr_nested")
+			d1.AnonymPtrNested = u1.AnonymPtrNested
+		}
+
+		if u1.AnonymPtr2 != nil {
+			if d1.AnonymPtr2 == nil {
+				d1.AnonymPtr2 = new(AnonymPtr2)
+			}
+			d2, u2 := d1.AnonymPtr2, u1.AnonymPtr2
+
+			if _, ok := keys["anonym_ptr2_string"]; (ok || keys == nil) && d2.AnonymPtr2String != u2.AnonymPtr2String {
+				updatedKeys = append(updatedKeys, "anonym_ptr2_string")
+				d2.AnonymPtr2String = u2.AnonymPtr2String
+			}
+
+			if _, ok := keys["anonym_ptr2_bool_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(d2.AnonymPtr2BoolPtr, u2.AnonymPtr2BoolPtr) {
+				updatedKeys = append(updatedKeys, "anonym_ptr2_bool_ptr")
+				d2.AnonymPtr2BoolPtr = u2.AnonymPtr2BoolPtr
+			}
+
+			if _, ok := keys["anonym_ptr2_nested_ptr"]; (ok || keys == nil) && !reflect.DeepEqual(d2.AnonymPtr2NestedPtr, u2.AnonymPtr2NestedPtr) {
+				updatedKeys = append(updatedKeys, "anonym_ptr2_nested_ptr")
+				d2.AnonymPtr2NestedPtr = u2.AnonymPtr2NestedPtr
+			}
+		}
+
+		if u1.Empty != nil {
+			if d1.Empty == nil {
+				d1.Empty = new(Empty)
+			}
+		}
+	}
+
+	if _, ok := keys["tagged"]; (ok || keys == nil) && !reflect.DeepEqual(dest.Tagged, update.Tagged) {
+		updatedKeys = append(updatedKeys, "tagged")
+		dest.Tagged = update.Tagged
+	}
+
+	if _, ok := keys["Label"]; (ok || keys == nil) && dest.Label != update.Label {
+		updatedKeys = append(updatedKeys, "Label")
+		dest.Label = update.Label
+	}
+
+	return updatedKeys
+}
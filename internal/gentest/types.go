@@ -0,0 +1,70 @@
+// Package gentest contains the types used to check code generated by shallowgen against the reflective implementation.
+package gentest
+
+import (
+	"time"
+)
+
+//go:generate go run ../../cmd/shallowgen -type=Test
+
+type Status string
+
+type Label string
+
+type Test struct {
+	String    string            `json:"string"`
+	StringPtr *string           `json:"string_ptr,omitempty"`
+	Bool      bool              `json:"bool,omitempty"`
+	BoolPtr   *bool             `json:"bool_ptr"`
+	Float     float64           `json:"float"`
+	Status    Status            `json:"status"`
+	Strings   []string          `json:"strings"`
+	Labels    map[string]string `json:"labels"`
+	Time      time.Time         `json:"time"`
+	TimePtr   *time.Time        `json:"time_ptr"`
+	Nested    Nested            `json:"nested"`
+	NestedPtr *Nested           `json:"nested_ptr"`
+	Untagged  string
+	Ignored   string `json:"-"`
+	ignored   string
+	Anonym
+	*AnonymPtr
+	Tagged `json:"tagged"`
+	Label
+}
+
+type Nested struct {
+	String    string  `json:"string,omitempty"`
+	StringPtr *string `json:"string_ptr"`
+	Bool      bool    `json:"bool"`
+	BoolPtr   *bool   `json:"bool_ptr"`
+}
+
+type Anonym struct {
+	AnonymString    string  `json:"anonym_string,omitempty"`
+	AnonymStringPtr *string `json:"anonym_string_ptr"`
+	AnonymNested    Nested  `json:"anonym_nested"`
+	AnonymNestedPtr *Nested `json:"anonym_nested_ptr"`
+}
+
+type AnonymPtr struct {
+	AnonymPtrString    string  `json:"anonym_ptr_string"`
+	AnonymPtrStringPtr *string `json:"anonym_ptr_string_ptr"`
+	AnonymPtrNested    Nested  `json:"anonym_ptr_nested"`
+	*AnonymPtr2
+	*Empty
+}
+
+type AnonymPtr2 struct {
+	AnonymPtr2String    string  `json:"anonym_ptr2_string"`
+	AnonymPtr2BoolPtr   *bool   `json:"anonym_ptr2_bool_ptr"`
+	AnonymPtr2NestedPtr *Nested `json:"anonym_ptr2_nested_ptr"`
+}
+
+type Tagged struct {
+	String string `json:"string"`
+}
+
+type Empty struct {
+	Untagged string
+}
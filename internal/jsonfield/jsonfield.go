@@ -0,0 +1,98 @@
+// Package jsonfield implements the rules of encoding/json for naming struct fields, shared by the shallow package
+// and the shallowgen command, which resolve the fields of reflect and go/types struct types respectively.
+package jsonfield
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Field is a field of a struct type, named the same way as by encoding/json.
+type Field struct {
+	Name string
+	// Tagged is true if the name is taken from the tag
+	Tagged bool
+	// Index of the field within the struct, including the indexes of the anonym fields containing it
+	Index []int
+}
+
+// TagName returns the name given in the tag value of a field, or an empty string if the tag doesn't give a name
+// accepted by encoding/json.
+func TagName(tagVal string) string {
+	name := strings.SplitN(tagVal, ",", 2)[0]
+	if !isValidName(name) {
+		return ""
+	}
+
+	return name
+}
+
+// Dominant returns the positions of the dominant fields within fields, the same way as encoding/json selects them:
+// only the least nested one is kept of the fields having the same name, or the tagged one if more of them are
+// equally nested. If this doesn't select a single field, none of them are kept.
+//
+// Fields of structs embedded multiple times at the same depth must be given multiple times, so that they collide.
+func Dominant(fields []Field) []int {
+	order := make([]int, len(fields))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := fields[order[i]], fields[order[j]]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if len(a.Index) != len(b.Index) {
+			return len(a.Index) < len(b.Index)
+		}
+		if a.Tagged != b.Tagged {
+			return a.Tagged
+		}
+
+		return LessIndex(a.Index, b.Index)
+	})
+
+	var dominant []int
+	for i := 0; i < len(order); {
+		j := i + 1
+		for j < len(order) && fields[order[j]].Name == fields[order[i]].Name {
+			j++
+		}
+		f := fields[order[i]]
+		if j == i+1 || len(f.Index) < len(fields[order[i+1]].Index) || f.Tagged != fields[order[i+1]].Tagged {
+			dominant = append(dominant, order[i])
+		}
+		i = j
+	}
+
+	return dominant
+}
+
+// LessIndex reports whether the field with index a comes before the field with index b in the struct.
+func LessIndex(a []int, b []int) bool {
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+// isValidName checks if encoding/json accepts the name given in a tag.
+func isValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// backslash and quote chars are reserved, other punctuation chars are allowed
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,53 @@
+package jsonfield
+
+import (
+	"testing"
+
+	"github.com/kr/pretty"
+)
+
+func TestTagName(t *testing.T) {
+	for tagVal, want := range map[string]string{
+		"":                "",
+		"name":            "name",
+		"name,omitempty":  "name",
+		",omitempty":      "",
+		"-,":              "-",
+		"with space":      "with space",
+		"in\\valid":       "",
+		"punct!#$%&()*+-": "punct!#$%&()*+-",
+	} {
+		if got := TagName(tagVal); got != want {
+			t.Errorf("%q: expected %q, got: %q", tagVal, want, got)
+		}
+	}
+}
+
+func TestDominant(t *testing.T) {
+	for name, data := range map[string]struct {
+		fields []Field
+		want   []int
+	}{
+		"unique": {
+			fields: []Field{{Name: "b", Index: []int{0}}, {Name: "a", Index: []int{1}}},
+			want:   []int{1, 0},
+		},
+		"least_nested": {
+			fields: []Field{{Name: "a", Index: []int{0, 0}}, {Name: "a", Index: []int{1}}},
+			want:   []int{1},
+		},
+		"tagged": {
+			fields: []Field{{Name: "a", Index: []int{0, 0}}, {Name: "a", Tagged: true, Index: []int{1, 0}}},
+			want:   []int{1},
+		},
+		"conflict": {
+			fields: []Field{{Name: "a", Index: []int{0, 0}}, {Name: "a", Index: []int{1, 0}}, {Name: "b", Index: []int{2}}},
+			want:   []int{2},
+		},
+	} {
+		got := Dominant(data.fields)
+		if diff := pretty.Diff(data.want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package shallow
+
+import (
+	"reflect"
+)
+
+// Inverse restores the fields changed by Merge to their previous values (see CollectInverse).
+type Inverse struct {
+	// Update is a pointer to a struct of the same type as dest, holding the previous values of the changed fields.
+	Update interface{}
+	// Keys selects the changed fields of Update, in the same format as the keys map of Merge.
+	Keys map[string]interface{}
+
+	allocated []fieldPath
+}
+
+// CollectInverse can be used to get the inverse of the changes made by Merge, e.g. to undo them later.
+// The inverse is stored in the given struct if Merge succeeds.
+//
+// Update and Keys restore the changed fields when passed to Merge with the Deep and MergeMaps options, except for
+// fields with a merge strategy (see StrategyTag). Elements removed from a slice merged by key are restored at the
+// end of the slice. Use Apply to restore all changes, including the strategies and the anonym struct pointers
+// allocated by Merge.
+func CollectInverse(inverse *Inverse) Option {
+	return func(o *options) {
+		o.inverse = inverse
+	}
+}
+
+// Apply restores the fields of dest changed by Merge to their previous values, ignoring the merge strategies
+// (see StrategyTag), and sets the anonym struct pointers allocated by Merge to nil.
+// Dest must be the struct that was passed to Merge, and the same tag option must be used.
+//
+// Returns with a list of restored keys, the same way as Merge.
+func (i *Inverse) Apply(dest interface{}, opts ...Option) (restoredKeys []string, err error) {
+	restoredKeys, err = Merge(dest, i.Update, i.Keys, withOptions(opts, Deep(), MergeMaps(), restoring())...)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	destV := reflect.ValueOf(dest).Elem()
+	for j := len(i.allocated) - 1; j >= 0; j-- {
+		o.release(destV, i.allocated[j])
+	}
+
+	return restoredKeys, nil
+}
+
+func restoring() Option {
+	return func(o *options) {
+		o.restoring = true
+	}
+}
+
+// collectInverse stores the inverse of the changes in o.inverse. The prevV struct is a copy of dest before Merge.
+func (o *options) collectInverse(prevV reflect.Value, changes []change) {
+	updateV := reflect.New(prevV.Type())
+	updateV.Elem().Set(prevV)
+	keys := make(map[string]interface{})
+	for _, c := range changes {
+		o.addPathKeys(keys, updateV.Elem(), c.path.steps)
+	}
+
+	*o.inverse = Inverse{
+		Update:    updateV.Interface(),
+		Keys:      keys,
+		allocated: o.allocated,
+	}
+}
+
+// addPathKeys adds the key of the field at the given path to the keys map, which selects the fields of the structV
+// update struct for Merge. Elements of slices merged by key which are missing from structV are added to it with
+// DeleteMarker in their keys map, as they must be removed from dest.
+func (o *options) addPathKeys(keys map[string]interface{}, structV reflect.Value, steps []pathStep) {
+	step := steps[0]
+	if step.key != "" {
+		sliceV, _ := lookupField(structV, o.tag, step.name, true)
+		elemType := sliceV.Type().Elem()
+		plan, err := getPlan(structType(elemType), o.tag)
+		if err != nil {
+			return
+		}
+
+		elemKeys, ok := keys[step.name].([]interface{})
+		if !ok {
+			// other elements are not processed
+			elemKeys = make([]interface{}, 0, sliceV.Len())
+			for i := 0; i < sliceV.Len(); i++ {
+				elemKeys = append(elemKeys, map[string]interface{}{})
+			}
+		}
+
+		j := o.indexByKey(plan, step.key, sliceV, reflect.ValueOf(step.id))
+		switch {
+		case j < 0:
+			elemV := reflect.New(elemType).Elem()
+			if elemType.Kind() == reflect.Ptr {
+				elemV.Set(reflect.New(elemType.Elem()))
+			}
+			idV, _ := plan.lookup(reflect.Indirect(elemV), step.key, true)
+			idV.Set(reflect.ValueOf(step.id))
+			sliceV.Set(reflect.Append(sliceV, elemV))
+			elemKeys = append(elemKeys, map[string]interface{}{DeleteMarker: true})
+
+		case len(steps) == 1:
+			elemKeys[j] = nil
+
+		default:
+			if nestedKeys, ok := elemKeys[j].(map[string]interface{}); ok {
+				o.addPathKeys(nestedKeys, reflect.Indirect(sliceV.Index(j)), steps[1:])
+			}
+		}
+		keys[step.name] = elemKeys
+
+		return
+	}
+
+	if len(steps) == 1 {
+		keys[step.name] = true
+		return
+	}
+
+	nestedKeys, ok := keys[step.name].(map[string]interface{})
+	if !ok {
+		if _, found := keys[step.name]; found {
+			// the field is restored as a whole
+			return
+		}
+		nestedKeys = make(map[string]interface{})
+		keys[step.name] = nestedKeys
+	}
+
+	fieldV, _ := lookupField(structV, o.tag, step.name, true)
+	fieldV = reflect.Indirect(fieldV)
+	if fieldV.Kind() != reflect.Struct {
+		// map entry
+		nestedKeys[steps[1].name] = true
+		return
+	}
+
+	o.addPathKeys(nestedKeys, fieldV, steps[1:])
+}
+
+// release sets the anonym struct pointer at the given path of the structV struct to nil.
+func (o *options) release(structV reflect.Value, path fieldPath) {
+	v, ok := o.resolvePath(structV, path)
+	if !ok {
+		return
+	}
+	v = reflect.Indirect(v)
+	if v.Kind() != reflect.Struct || !v.CanSet() {
+		return
+	}
+
+	ptrV, err := v.FieldByIndexErr(path.index)
+	if err != nil {
+		return
+	}
+	ptrV.Set(reflect.Zero(ptrV.Type()))
+}
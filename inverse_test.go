@@ -0,0 +1,139 @@
+package shallow
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+type inverseTest struct {
+	*AnonymPtr
+	String    string            `json:"string"`
+	OwnerID   string            `json:"owner_id" shallow:"writeonce"`
+	Tags      []string          `json:"tags" shallow:"append"`
+	Labels    map[string]string `json:"labels"`
+	Items     []*keyedItem      `json:"items" shallow:"key=id"`
+	NestedPtr *Nested           `json:"nested_ptr"`
+	Nested    *Nested           `json:"nested"`
+}
+
+func inverseTestData() inverseTest {
+	return inverseTest{
+		String: "string_val",
+		Tags:   []string{"a"},
+		Labels: map[string]string{"env": "prod", "team": "core"},
+		Items: []*keyedItem{
+			{ID: 1, Name: "first", Qty: 1},
+			{ID: 2, Name: "second", Qty: 2},
+		},
+		NestedPtr: &Nested{String: "nested_ptr_string_val"},
+	}
+}
+
+func TestInverse(t *testing.T) {
+	incoming := `{
+		"anonym_ptr_string": "anonym",
+		"string": "test2",
+		"owner_id": "owner",
+		"tags": ["b"],
+		"labels": {"env": "dev", "team": null, "app": "shallow"},
+		"items": [{"id": 1, "$delete": true}, {"id": 2, "qty": 3}, {"id": 3, "name": "third"}],
+		"nested_ptr": {"bool": true},
+		"nested": {"string": "nested_string_val"}
+	}`
+	update := inverseTest{}
+	err := json.Unmarshal([]byte(incoming), &update)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	var keys map[string]interface{}
+	err = json.Unmarshal([]byte(incoming), &keys)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	got := inverseTestData()
+	inverse := Inverse{}
+	_, err = Merge(&got, &update, keys, Deep(), MergeMaps(), CollectInverse(&inverse))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	wantKeys := map[string]interface{}{
+		"anonym_ptr_string": true,
+		"string":            true,
+		"owner_id":          true,
+		"tags":              true,
+		"labels":            map[string]interface{}{"env": true, "team": true, "app": true},
+		"items": []interface{}{
+			nil,
+			map[string]interface{}{"qty": true},
+			map[string]interface{}{DeleteMarker: true},
+		},
+		"nested_ptr": map[string]interface{}{"bool": true},
+		"nested":     true,
+	}
+	if diff := pretty.Diff(wantKeys, inverse.Keys); len(diff) > 0 {
+		t.Errorf("inverse keys: diffs (want/got): %v", diff)
+	}
+
+	restoredKeys, err := inverse.Apply(&got)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	want := inverseTestData()
+	// removed elements are restored at the end of the slice
+	want.Items = []*keyedItem{want.Items[1], want.Items[0]}
+	if diff := pretty.Diff(want, got); len(diff) > 0 {
+		t.Errorf("restored: diffs (want/got): %v", diff)
+	}
+
+	wantRestoredKeys := []string{
+		"string",
+		"owner_id",
+		"tags",
+		"labels.app",
+		"labels.env",
+		"labels.team",
+		"items[id=1]",
+		"items[id=2].qty",
+		"items[id=3]",
+		"nested_ptr.bool",
+		"nested",
+	}
+	if diff := pretty.Diff(wantRestoredKeys, restoredKeys); len(diff) > 0 {
+		t.Errorf("restoredKeys: diffs (want/got): %v", diff)
+	}
+}
+
+func TestInverseMerge(t *testing.T) {
+	got := testData(nil)
+	update := testData(func(t test) test {
+		t.String = "test2"
+		t.StringPtr = nil
+		t.NestedPtr.Bool = false
+		return t
+	})
+	keys := map[string]interface{}{
+		"string":     nil,
+		"string_ptr": nil,
+		"nested_ptr": map[string]interface{}{"bool": nil},
+	}
+
+	inverse := Inverse{}
+	_, err := Merge(&got, &update, keys, Deep(), CollectInverse(&inverse))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	_, err = Merge(&got, inverse.Update, inverse.Keys, Deep(), MergeMaps())
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	if diff := pretty.Diff(testData(nil), got); len(diff) > 0 {
+		t.Errorf("restored: diffs (want/got): %v", diff)
+	}
+}
@@ -0,0 +1,182 @@
+package shallow
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/proemergotech/shallow/internal/jsonfield"
+)
+
+// JSONNames can be used to select whether keys are resolved to fields the same way as encoding/json names them:
+//   - fields without a name in their tag are named by their Go field name, and names which are not valid for
+//     encoding/json are replaced by the Go field name as well,
+//   - fields tagged "-" and unexported fields are skipped,
+//   - anonym struct and struct pointer fields with a name in their tag are processed as named fields, other anonym
+//     struct and struct pointer fields are traversed,
+//   - other anonym fields are named by their type name, unless the type is unexported,
+//   - if multiple fields have the same name, the least nested one is used, or the tagged one if more of them are
+//     equally nested. If this doesn't select a single field, none of them are used.
+//
+// Enabled by default for the json tag, and disabled for other tags (see UseTag), in which case only the fields
+// having the tag are processed, named by the first segment of their tag as is.
+func JSONNames(enabled bool) Option {
+	return func(o *options) {
+		o.jsonNames = &enabled
+	}
+}
+
+// jsonField is a field of a struct type, named the same way as by encoding/json.
+type jsonField struct {
+	jsonfield.Field
+	field reflect.StructField
+	// embedded is true for traversed anonym struct pointer fields
+	embedded bool
+}
+
+type jsonStruct struct {
+	typ   reflect.Type
+	index []int
+}
+
+// compileJSONPlan compiles the plan of the t struct type, naming the fields the same way as encoding/json.
+func compileJSONPlan(t reflect.Type, tag string) (*structPlan, error) {
+	plan := &structPlan{}
+	for _, f := range jsonFields(t, tag) {
+		err := plan.addJSONField(t, f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// jsonFields returns the fields of the t struct type in index order, the same way as encoding/json resolves them:
+// anonym struct fields are traversed breadth first, and only the dominant field is kept of the fields having the
+// same name. Traversed anonym struct pointer fields are returned as well.
+func jsonFields(t reflect.Type, tag string) []jsonField {
+	var fields []jsonField
+	var embedded []jsonField
+
+	next := []jsonStruct{{typ: t}}
+	var count, nextCount map[reflect.Type]int
+	visited := make(map[reflect.Type]bool)
+	for len(next) > 0 {
+		current := next
+		next = nil
+		count, nextCount = nextCount, make(map[reflect.Type]int)
+
+		for _, s := range current {
+			if visited[s.typ] {
+				continue
+			}
+			visited[s.typ] = true
+
+			for i := 0; i < s.typ.NumField(); i++ {
+				sf := s.typ.Field(i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if !sf.IsExported() && (!sf.Anonymous || ft.Kind() != reflect.Struct) {
+					continue
+				}
+
+				tagVal := sf.Tag.Get(tag)
+				if tagVal == "-" {
+					continue
+				}
+				name := jsonfield.TagName(tagVal)
+
+				index := append(s.index[:len(s.index):len(s.index)], i)
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					f := jsonField{Field: jsonfield.Field{Name: name, Tagged: name != "", Index: index}, field: sf}
+					if name == "" {
+						f.Name = sf.Name
+					}
+					fields = append(fields, f)
+					if count[s.typ] > 1 {
+						// the struct is embedded multiple times at the same depth, so its fields must collide
+						fields = append(fields, f)
+					}
+
+					continue
+				}
+
+				if sf.Type.Kind() == reflect.Ptr {
+					embedded = append(embedded, jsonField{Field: jsonfield.Field{Index: index}, field: sf, embedded: true})
+				}
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, jsonStruct{typ: ft, index: index})
+				}
+			}
+		}
+	}
+
+	names := make([]jsonfield.Field, 0, len(fields))
+	for _, f := range fields {
+		names = append(names, f.Field)
+	}
+	dominant := embedded
+	for _, i := range jsonfield.Dominant(names) {
+		dominant = append(dominant, fields[i])
+	}
+	sort.Slice(dominant, func(i, j int) bool {
+		return jsonfield.LessIndex(dominant[i].Index, dominant[j].Index)
+	})
+
+	return dominant
+}
+
+// addJSONField adds the f field of the t struct type to the plan, following the anonym struct pointer fields
+// containing it into their plans.
+func (p *structPlan) addJSONField(t reflect.Type, f jsonField) error {
+	plans := []*structPlan{p}
+	start := 0
+	for k := 0; k < len(f.Index)-1; k++ {
+		t = t.Field(f.Index[k]).Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+			plans = append(plans, plans[len(plans)-1].embeddedPlan(f.Index[start:k+1]))
+			start = k + 1
+		}
+	}
+
+	plan := plans[len(plans)-1]
+	if f.embedded {
+		plan.embeddedPlan(f.Index[start:])
+		return nil
+	}
+
+	fp, err := newFieldPlan(f.field, f.Index[start:], f.Name)
+	if err != nil {
+		return err
+	}
+	if fp.strategy == strategyVersion {
+		for _, plan := range plans {
+			err = plan.setVersion(fp.name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	plan.fields = append(plan.fields, fp)
+
+	return nil
+}
+
+// embeddedPlan returns the plan of the anonym struct pointer field with the given index. As fields are added in
+// index order, the field is either the last one of the plan, or it is added now.
+func (p *structPlan) embeddedPlan(index []int) *structPlan {
+	if n := len(p.fields); n > 0 && p.fields[n-1].embedded != nil && reflect.DeepEqual(p.fields[n-1].index, index) {
+		return p.fields[n-1].embedded
+	}
+
+	p.fields = append(p.fields, fieldPlan{
+		index:    index,
+		embedded: &structPlan{},
+	})
+
+	return p.fields[len(p.fields)-1].embedded
+}
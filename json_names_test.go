@@ -0,0 +1,164 @@
+package shallow
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+type JSONLabel string
+
+type jsonInner struct {
+	InnerString string
+	Shared      string
+}
+
+type JSONEmbedded struct {
+	String   string
+	Tie      string `json:"Tie"`
+	Untie    string
+	Embedded string `json:"embedded"`
+}
+
+type JSONEmbedded2 struct {
+	Tie       string
+	Untie     string
+	Embedded2 string `json:"embedded2"`
+}
+
+type JSONObject struct {
+	String string `json:"string"`
+}
+
+type jsonNamesTest struct {
+	String     string
+	Renamed    string `json:"renamed,omitempty"`
+	OmitEmpty  string `json:",omitempty"`
+	Skipped    string `json:"-"`
+	Dash       string `json:"-,"`
+	unexported string
+	NestedPtr  *Nested `json:"nested_ptr"`
+	JSONLabel
+	jsonInner
+	*JSONEmbedded
+	JSONEmbedded2
+	JSONObject `json:"object"`
+}
+
+func jsonNamesTestData() jsonNamesTest {
+	return jsonNamesTest{
+		String:     "string_val",
+		Renamed:    "renamed_val",
+		OmitEmpty:  "omit_empty_val",
+		Skipped:    "skipped_val",
+		Dash:       "dash_val",
+		unexported: "unexported_val",
+		NestedPtr:  &Nested{String: "nested_ptr_string_val"},
+		JSONLabel:  "label_val",
+		jsonInner: jsonInner{
+			InnerString: "inner_string_val",
+			Shared:      "shared_val",
+		},
+		JSONEmbedded: &JSONEmbedded{
+			String:   "embedded_string_val",
+			Tie:      "embedded_tie_val",
+			Untie:    "embedded_untie_val",
+			Embedded: "embedded_val",
+		},
+		JSONEmbedded2: JSONEmbedded2{
+			Tie:       "embedded2_tie_val",
+			Untie:     "embedded2_untie_val",
+			Embedded2: "embedded2_val",
+		},
+		JSONObject: JSONObject{String: "object_string_val"},
+	}
+}
+
+// TestJSONNamesConformance checks that keys are resolved to fields exactly the same way as by encoding/json.
+func TestJSONNamesConformance(t *testing.T) {
+	data, err := json.Marshal(jsonNamesTestData())
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	var marshaled map[string]interface{}
+	err = json.Unmarshal(data, &marshaled)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	wantKeys := make([]string, 0, len(marshaled))
+	for key := range marshaled {
+		wantKeys = append(wantKeys, key)
+	}
+	sort.Strings(wantKeys)
+
+	update := jsonNamesTestData()
+	gotKeys, err := Diff(&jsonNamesTest{}, &update, nil)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	sort.Strings(gotKeys)
+	if diff := pretty.Diff(wantKeys, gotKeys); len(diff) > 0 {
+		t.Errorf("keys: diffs (want/got): %v", diff)
+	}
+
+	var want jsonNamesTest
+	err = json.Unmarshal(data, &want)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	var got jsonNamesTest
+	_, err = MergePatchJSON(&got, data)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	if diff := pretty.Diff(want, got); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", diff)
+	}
+}
+
+func TestJSONNames(t *testing.T) {
+	type testWithTags struct {
+		String   string `json:"string" patch:"str"`
+		Untagged string
+		Skipped  string `json:"-" patch:"-"`
+	}
+
+	for name, data := range map[string]struct {
+		opts     []Option
+		wantKeys []string
+	}{
+		"json": {
+			wantKeys: []string{"string", "Untagged"},
+		},
+		"json_disabled": {
+			opts:     []Option{JSONNames(false)},
+			wantKeys: []string{"string", "-"},
+		},
+		"other_tag": {
+			opts:     []Option{UseTag("patch")},
+			wantKeys: []string{"str", "-"},
+		},
+		"other_tag_enabled": {
+			opts:     []Option{JSONNames(true), UseTag("patch")},
+			wantKeys: []string{"str", "Untagged"},
+		},
+	} {
+		update := testWithTags{
+			String:   "string_val",
+			Untagged: "untagged_val",
+			Skipped:  "skipped_val",
+		}
+		gotKeys, err := Diff(&testWithTags{}, &update, nil, data.opts...)
+		if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+
+		if diff := pretty.Diff(data.wantKeys, gotKeys); len(diff) > 0 {
+			t.Errorf("%v keys: diffs (want/got): %v", name, diff)
+		}
+	}
+}
@@ -0,0 +1,698 @@
+package shallow
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/proemergotech/errors/v2"
+)
+
+// JSON Patch (RFC 6902) operation types.
+const (
+	PatchOpAdd     = "add"
+	PatchOpRemove  = "remove"
+	PatchOpReplace = "replace"
+	PatchOpTest    = "test"
+	PatchOpCopy    = "copy"
+	PatchOpMove    = "move"
+)
+
+// PatchOperation is a single JSON Patch (RFC 6902) operation, which can be marshaled with encoding/json.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON always includes the value of add, replace and test operations, even if it is nil, and omits it for
+// other operations.
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	marshaled := struct {
+		Op    string       `json:"op"`
+		Path  string       `json:"path"`
+		From  string       `json:"from,omitempty"`
+		Value *interface{} `json:"value,omitempty"`
+	}{
+		Op:   op.Op,
+		Path: op.Path,
+		From: op.From,
+	}
+	switch op.Op {
+	case PatchOpAdd, PatchOpReplace, PatchOpTest:
+		marshaled.Value = &op.Value
+	}
+
+	return json.Marshal(marshaled)
+}
+
+// PatchTestError is returned by ApplyJSONPatch if the value of a test operation does not match.
+type PatchTestError struct {
+	// Index of the failed operation in the patch.
+	Index int
+	Path  string
+}
+
+func (e *PatchTestError) Error() string {
+	return fmt.Sprintf("JSON patch operation %d: test failed for path %q", e.Index, e.Path)
+}
+
+// DiffPatch compares structs the same way as Diff with the Deep option, and returns the differences as JSON Patch
+// (RFC 6902) operations that transform the first struct into the second.
+//
+// Paths are JSON Pointers (RFC 6901) built from the field tags (specified by tag option, default "json").
+// Pointer fields changing from nil and elements added to slices merged by key (see StrategyTag) produce an add
+// operation, pointer fields changing to nil and removed elements produce a remove operation, and all other
+// differences produce a replace operation. Element indexes are valid when the operations are applied in order.
+func DiffPatch(first interface{}, second interface{}, keys map[string]interface{}, opts ...Option) (operations []PatchOperation, err error) {
+	changes, err := processChanges(first, second, keys, false, withOptions(opts, Deep())...)
+	if err != nil {
+		return nil, err
+	}
+
+	operations = make([]PatchOperation, 0, len(changes))
+	for _, c := range changes {
+		op := PatchOperation{
+			Op:    PatchOpReplace,
+			Path:  jsonPointer(c.path.tokens()),
+			Value: c.newValue.Interface(),
+		}
+		if c.op == PatchOpAdd || (c.oldValue.Kind() == reflect.Ptr && c.oldValue.IsNil()) {
+			op.Op = PatchOpAdd
+		} else if c.op == PatchOpRemove || (c.newValue.Kind() == reflect.Ptr && c.newValue.IsNil()) {
+			op.Op = PatchOpRemove
+			op.Value = nil
+		}
+
+		operations = append(operations, op)
+	}
+
+	return operations, nil
+}
+
+// ApplyJSONPatch applies JSON Patch (RFC 6902) operations to the dest struct.
+//
+// Dest must be a non-nil pointer to a struct. Paths are JSON Pointers (RFC 6901), resolved to struct fields by their
+// tag (specified by tag option, default "json") the same way as in Merge, and to slice, array and map elements by
+// index and key. Values are decoded into the type of the field or element they are applied to.
+// Removing a struct field sets it to its zero value.
+//
+// The operations are applied atomically: if any of them fails, dest is left untouched. A failing test operation
+// returns a *PatchTestError. Only the top level fields affected by the operations are set in dest, other fields
+// are left as they are.
+//
+// Changes of readonly, writeonce and version fields (see StrategyTag) on the paths of the operations are skipped,
+// or rejected with a *ProtectedFieldsError if the RejectProtected option is used. The affected keys are authorized
+// by the authorizer (see WithAuthorizer) before changing dest.
+//
+// Returns with a list of affected keys, with nested keys returned as dotted paths (see Deep).
+func ApplyJSONPatch(dest interface{}, ops []byte, opts ...Option) (affectedKeys []string, err error) {
+	err = checkStructPointer(dest)
+	if err != nil {
+		return nil, err
+	}
+	destV := reflect.ValueOf(dest)
+
+	o := newOptions(opts)
+
+	_, err = getPlan(destV.Type().Elem(), o.tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []rawPatchOperation
+	err = json.Unmarshal(ops, &operations)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode JSON patch")
+	}
+
+	// the operations are applied to a copy, and only the affected fields are copied back to dest
+	docV := deepCopy(destV.Elem())
+	var affected [][]string
+	seen := make(map[string]bool)
+	for i, op := range operations {
+		paths, err := applyPatchOperation(docV, op, o)
+		if err != nil {
+			if testErr, ok := err.(*PatchTestError); ok {
+				testErr.Index = i
+				return nil, testErr
+			}
+
+			return nil, errors.Wrapf(err, "JSON patch operation %d (%s %q) failed", i, op.Op, op.Path)
+		}
+
+		for _, path := range paths {
+			if key := strings.Join(path, "."); !seen[key] {
+				seen[key] = true
+				affected = append(affected, path)
+			}
+		}
+	}
+
+	affected, err = o.protectPatch(destV.Elem(), docV, affected)
+	if err != nil {
+		return nil, err
+	}
+
+	affectedKeys = make([]string, 0, len(affected))
+	for _, path := range affected {
+		affectedKeys = append(affectedKeys, strings.Join(path, "."))
+	}
+	err = o.authorize(affectedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := make(map[string]bool)
+	for _, path := range affected {
+		if copied[path[0]] {
+			continue
+		}
+		copied[path[0]] = true
+
+		docFieldV, _ := lookupField(docV, o.tag, path[0], false)
+		destFieldV, _ := lookupField(destV.Elem(), o.tag, path[0], false)
+		if reflect.DeepEqual(destFieldV.Interface(), docFieldV.Interface()) {
+			continue
+		}
+		// look up the field again, allocating the anonym struct pointers containing it
+		destFieldV, _ = lookupField(destV.Elem(), o.tag, path[0], true)
+		o.set(destFieldV, docFieldV)
+	}
+
+	return affectedKeys, nil
+}
+
+// protectPatch checks the affected paths of docV for changed protected fields, comparing them to currentV. The
+// changed protected fields are either rejected, or restored in docV, and the paths leading to them are dropped.
+func (o *options) protectPatch(currentV reflect.Value, docV reflect.Value, affected [][]string) ([][]string, error) {
+	var protected [][]string
+	var protectedValues []reflect.Value
+	var rejected []string
+	seen := make(map[string]bool)
+	unprotected := make([][]string, 0, len(affected))
+	for _, path := range affected {
+		n, currentFieldV := o.protectedPatchField(currentV, docV, path)
+		if n == 0 {
+			unprotected = append(unprotected, path)
+			continue
+		}
+
+		if key := strings.Join(path[:n], "."); !seen[key] {
+			seen[key] = true
+			rejected = append(rejected, key)
+			protected = append(protected, path[:n])
+			protectedValues = append(protectedValues, currentFieldV)
+		}
+	}
+	if o.rejectProtected && len(rejected) > 0 {
+		return nil, &ProtectedFieldsError{Keys: rejected}
+	}
+
+	for i, path := range protected {
+		err := resolvePointer(docV, path, o.tag, func(containerV reflect.Value, token string) error {
+			return patchSet(containerV, token, o.tag, copiedValue(deepCopy(protectedValues[i])), true)
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot restore protected field %q", strings.Join(path, "."))
+		}
+	}
+
+	return unprotected, nil
+}
+
+// protectedPatchField walks the path within currentV and docV, and returns the length of the path leading to the
+// first protected field whose value differs, along with its value in currentV. Returns 0 if there is no such field.
+// Values missing from currentV are treated as zero values.
+func (o *options) protectedPatchField(currentV reflect.Value, docV reflect.Value, path []string) (int, reflect.Value) {
+	for i, token := range path {
+		for docV.Kind() == reflect.Ptr || docV.Kind() == reflect.Interface {
+			if docV.IsNil() {
+				return 0, reflect.Value{}
+			}
+			docV = docV.Elem()
+			if currentV.IsNil() || currentV.Elem().Type() != docV.Type() {
+				currentV = reflect.Zero(docV.Type())
+			} else {
+				currentV = currentV.Elem()
+			}
+		}
+
+		switch docV.Kind() {
+		case reflect.Struct:
+			plan, err := getPlan(docV.Type(), o.tag)
+			if err != nil {
+				return 0, reflect.Value{}
+			}
+			f := plan.find(token)
+			if f == nil {
+				return 0, reflect.Value{}
+			}
+			currentFieldV, _ := plan.lookup(currentV, token, false)
+			docFieldV, _ := plan.lookup(docV, token, false)
+			if f.protected(currentFieldV) && !o.equal(currentFieldV, docFieldV, f.equal) {
+				return i + 1, currentFieldV
+			}
+			currentV, docV = currentFieldV, docFieldV
+
+		case reflect.Slice, reflect.Array:
+			j, err := sliceIndex(docV, token, false)
+			if err != nil {
+				return 0, reflect.Value{}
+			}
+			docV = docV.Index(j)
+			if j < currentV.Len() {
+				currentV = currentV.Index(j)
+			} else {
+				currentV = reflect.Zero(docV.Type())
+			}
+
+		case reflect.Map:
+			keyV, err := mapKey(docV, token)
+			if err != nil {
+				return 0, reflect.Value{}
+			}
+			docV = docV.MapIndex(keyV)
+			if !docV.IsValid() {
+				return 0, reflect.Value{}
+			}
+			currentV = currentV.MapIndex(keyV)
+			if !currentV.IsValid() {
+				currentV = reflect.Zero(docV.Type())
+			}
+
+		default:
+			return 0, reflect.Value{}
+		}
+	}
+
+	return 0, reflect.Value{}
+}
+
+type rawPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyPatchOperation applies a single operation to docV, and returns the paths of the affected values.
+func applyPatchOperation(docV reflect.Value, op rawPatchOperation, o *options) ([][]string, error) {
+	tag := o.tag
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return nil, errors.New("operations on the whole document are not supported")
+	}
+
+	switch op.Op {
+	case PatchOpAdd, PatchOpReplace, PatchOpTest:
+		if op.Value == nil {
+			return nil, errors.New("missing value")
+		}
+	}
+
+	switch op.Op {
+	case PatchOpAdd, PatchOpReplace:
+		value := decodedValue(op.Value, tag, op.Path)
+		err = resolvePointer(docV, path, tag, func(containerV reflect.Value, token string) error {
+			return patchSet(containerV, token, tag, value, op.Op == PatchOpReplace)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return [][]string{path}, nil
+
+	case PatchOpRemove:
+		err = resolvePointer(docV, path, tag, func(containerV reflect.Value, token string) error {
+			return patchRemove(containerV, token, tag)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return [][]string{path}, nil
+
+	case PatchOpTest:
+		value, err := patchGet(docV, path, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		expectedV := reflect.New(value.Type()).Elem()
+		err = decodeValue(expectedV, op.Value, tag, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !o.equal(value, expectedV, equalMethod(value.Type())) {
+			return nil, &PatchTestError{Path: op.Path}
+		}
+
+		return nil, nil
+
+	case PatchOpCopy, PatchOpMove:
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(from) == 0 {
+			return nil, errors.New("operations on the whole document are not supported")
+		}
+
+		value, err := patchGet(docV, from, tag)
+		if err != nil {
+			return nil, err
+		}
+		value = deepCopy(value)
+
+		if op.Op == PatchOpCopy {
+			err = resolvePointer(docV, path, tag, func(containerV reflect.Value, token string) error {
+				return patchSet(containerV, token, tag, copiedValue(value), false)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return [][]string{path}, nil
+		}
+
+		if op.Path == op.From {
+			return nil, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, errors.New("cannot move a value into one of its children")
+		}
+		err = resolvePointer(docV, from, tag, func(containerV reflect.Value, token string) error {
+			return patchRemove(containerV, token, tag)
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = resolvePointer(docV, path, tag, func(containerV reflect.Value, token string) error {
+			return patchSet(containerV, token, tag, copiedValue(value), false)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return [][]string{from, path}, nil
+
+	default:
+		return nil, errors.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// patchValue returns the value to be set for a container element of the given type.
+type patchValue func(t reflect.Type) (reflect.Value, error)
+
+func decodedValue(raw json.RawMessage, tag fieldTag, path string) patchValue {
+	return func(t reflect.Type) (reflect.Value, error) {
+		v := reflect.New(t).Elem()
+		err := decodeValue(v, raw, tag, path)
+
+		return v, err
+	}
+}
+
+func copiedValue(v reflect.Value) patchValue {
+	return func(t reflect.Type) (reflect.Value, error) {
+		if !v.Type().AssignableTo(t) {
+			return reflect.Value{}, errors.Errorf("cannot assign value of type %v to %v", v.Type(), t)
+		}
+
+		return v, nil
+	}
+}
+
+// resolvePointer resolves the path within docV up to its last token, and calls fn with the container value holding
+// the last token. Containers are always addressable, map elements and interface values are copied and set back
+// after fn succeeds.
+func resolvePointer(docV reflect.Value, path []string, tag fieldTag, fn func(containerV reflect.Value, token string) error) error {
+	for docV.Kind() == reflect.Ptr || docV.Kind() == reflect.Interface {
+		if docV.IsNil() {
+			return errors.Errorf("path not found: %q", jsonPointer(path))
+		}
+		if docV.Kind() == reflect.Interface {
+			elemV := reflect.New(docV.Elem().Type()).Elem()
+			elemV.Set(docV.Elem())
+			err := resolvePointer(elemV, path, tag, fn)
+			if err != nil {
+				return err
+			}
+			docV.Set(elemV)
+
+			return nil
+		}
+		docV = docV.Elem()
+	}
+
+	if len(path) == 1 {
+		return fn(docV, path[0])
+	}
+
+	switch docV.Kind() {
+	case reflect.Struct:
+		fieldV, ok := lookupField(docV, tag, path[0], true)
+		if !ok {
+			return errors.Errorf("path not found: %q", jsonPointer(path))
+		}
+
+		return resolvePointer(fieldV, path[1:], tag, fn)
+
+	case reflect.Slice, reflect.Array:
+		i, err := sliceIndex(docV, path[0], false)
+		if err != nil {
+			return err
+		}
+
+		return resolvePointer(docV.Index(i), path[1:], tag, fn)
+
+	case reflect.Map:
+		keyV, err := mapKey(docV, path[0])
+		if err != nil {
+			return err
+		}
+		elemV := docV.MapIndex(keyV)
+		if !elemV.IsValid() {
+			return errors.Errorf("path not found: %q", jsonPointer(path))
+		}
+		copyV := reflect.New(elemV.Type()).Elem()
+		copyV.Set(elemV)
+		err = resolvePointer(copyV, path[1:], tag, fn)
+		if err != nil {
+			return err
+		}
+		docV.SetMapIndex(keyV, copyV)
+
+		return nil
+
+	default:
+		return errors.Errorf("path not found: %q", jsonPointer(path))
+	}
+}
+
+func patchGet(docV reflect.Value, path []string, tag fieldTag) (value reflect.Value, err error) {
+	err = resolvePointer(docV, path, tag, func(containerV reflect.Value, token string) error {
+		switch containerV.Kind() {
+		case reflect.Struct:
+			fieldV, ok := lookupField(containerV, tag, token, false)
+			if !ok {
+				return errors.Errorf("field not found: %q", token)
+			}
+			value = fieldV
+
+		case reflect.Slice, reflect.Array:
+			i, err := sliceIndex(containerV, token, false)
+			if err != nil {
+				return err
+			}
+			value = containerV.Index(i)
+
+		case reflect.Map:
+			keyV, err := mapKey(containerV, token)
+			if err != nil {
+				return err
+			}
+			value = containerV.MapIndex(keyV)
+			if !value.IsValid() {
+				return errors.Errorf("map key not found: %q", token)
+			}
+
+		default:
+			return errors.Errorf("cannot get %q from value of kind %v", token, containerV.Kind())
+		}
+
+		return nil
+	})
+
+	return value, err
+}
+
+func patchSet(containerV reflect.Value, token string, tag fieldTag, value patchValue, replace bool) error {
+	switch containerV.Kind() {
+	case reflect.Struct:
+		fieldV, ok := lookupField(containerV, tag, token, true)
+		if !ok {
+			return errors.Errorf("field not found: %q", token)
+		}
+		v, err := value(fieldV.Type())
+		if err != nil {
+			return err
+		}
+		fieldV.Set(v)
+
+	case reflect.Slice:
+		i, err := sliceIndex(containerV, token, !replace)
+		if err != nil {
+			return err
+		}
+		v, err := value(containerV.Type().Elem())
+		if err != nil {
+			return err
+		}
+		if replace {
+			containerV.Index(i).Set(v)
+			return nil
+		}
+		newV := reflect.MakeSlice(containerV.Type(), containerV.Len()+1, containerV.Len()+1)
+		reflect.Copy(newV, containerV.Slice(0, i))
+		newV.Index(i).Set(v)
+		reflect.Copy(newV.Slice(i+1, newV.Len()), containerV.Slice(i, containerV.Len()))
+		containerV.Set(newV)
+
+	case reflect.Array:
+		i, err := sliceIndex(containerV, token, false)
+		if err != nil {
+			return err
+		}
+		v, err := value(containerV.Type().Elem())
+		if err != nil {
+			return err
+		}
+		containerV.Index(i).Set(v)
+
+	case reflect.Map:
+		keyV, err := mapKey(containerV, token)
+		if err != nil {
+			return err
+		}
+		if replace && !containerV.MapIndex(keyV).IsValid() {
+			return errors.Errorf("map key not found: %q", token)
+		}
+		v, err := value(containerV.Type().Elem())
+		if err != nil {
+			return err
+		}
+		if containerV.IsNil() {
+			containerV.Set(reflect.MakeMap(containerV.Type()))
+		}
+		containerV.SetMapIndex(keyV, v)
+
+	default:
+		return errors.Errorf("cannot set %q on value of kind %v", token, containerV.Kind())
+	}
+
+	return nil
+}
+
+func patchRemove(containerV reflect.Value, token string, tag fieldTag) error {
+	switch containerV.Kind() {
+	case reflect.Struct:
+		fieldV, ok := lookupField(containerV, tag, token, false)
+		if !ok {
+			return errors.Errorf("field not found: %q", token)
+		}
+		if fieldV.CanSet() {
+			fieldV.Set(reflect.Zero(fieldV.Type()))
+		}
+
+	case reflect.Slice:
+		i, err := sliceIndex(containerV, token, false)
+		if err != nil {
+			return err
+		}
+		newV := reflect.MakeSlice(containerV.Type(), containerV.Len()-1, containerV.Len()-1)
+		reflect.Copy(newV, containerV.Slice(0, i))
+		reflect.Copy(newV.Slice(i, newV.Len()), containerV.Slice(i+1, containerV.Len()))
+		containerV.Set(newV)
+
+	case reflect.Map:
+		keyV, err := mapKey(containerV, token)
+		if err != nil {
+			return err
+		}
+		if !containerV.MapIndex(keyV).IsValid() {
+			return errors.Errorf("map key not found: %q", token)
+		}
+		containerV.SetMapIndex(keyV, reflect.Value{})
+
+	default:
+		return errors.Errorf("cannot remove %q from value of kind %v", token, containerV.Kind())
+	}
+
+	return nil
+}
+
+// sliceIndex parses an array index token. If add is true, the index may point right after the last element,
+// which can also be referred to by "-".
+func sliceIndex(sliceV reflect.Value, token string, add bool) (int, error) {
+	if add && token == "-" {
+		return sliceV.Len(), nil
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.Errorf("invalid array index: %q", token)
+	}
+	if i > sliceV.Len() || (!add && i == sliceV.Len()) {
+		return 0, errors.Errorf("array index out of range: %q", token)
+	}
+
+	return i, nil
+}
+
+func mapKey(mapV reflect.Value, token string) (reflect.Value, error) {
+	if mapV.Type().Key().Kind() != reflect.String {
+		return reflect.Value{}, errors.Errorf("unsupported map key type: %v", mapV.Type().Key())
+	}
+
+	return reflect.ValueOf(token).Convert(mapV.Type().Key()), nil
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// jsonPointer builds a JSON Pointer (RFC 6901) from the given reference tokens.
+func jsonPointer(path []string) string {
+	b := strings.Builder{}
+	for _, token := range path {
+		b.WriteString("/")
+		b.WriteString(jsonPointerEscaper.Replace(token))
+	}
+
+	return b.String()
+}
+
+var jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parseJSONPointer parses a JSON Pointer (RFC 6901) into its reference tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, errors.Errorf("invalid JSON pointer: %q", pointer)
+	}
+
+	path := strings.Split(pointer[1:], "/")
+	for i, token := range path {
+		path[i] = jsonPointerUnescaper.Replace(token)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,310 @@
+package shallow
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+func TestDiffPatch(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		first   test
+		second  test
+		keys    map[string]interface{}
+		wantOps string
+	}{
+		"same": {
+			first:   testData(nil),
+			second:  testData(nil),
+			wantOps: `[]`,
+		},
+		"replace": {
+			first: testData(nil),
+			second: testData(func(t test) test {
+				t.String = "test2"
+				t.Nested.Bool = false
+				t.AnonymPtr2.AnonymPtr2BoolPtr = boolPtr(false)
+				return t
+			}),
+			wantOps: `[
+				{"op":"replace","path":"/string","value":"test2"},
+				{"op":"replace","path":"/nested/bool","value":false},
+				{"op":"replace","path":"/anonym_ptr2_bool_ptr","value":false}
+			]`,
+		},
+		"add": {
+			first: testData(func(t test) test {
+				t.StringPtr = nil
+				t.NestedPtr = nil
+				return t
+			}),
+			second: testData(nil),
+			wantOps: `[
+				{"op":"add","path":"/string_ptr","value":"string_ptr_val"},
+				{"op":"add","path":"/nested_ptr","value":{"string":"nested_ptr_string_val","string_ptr":"nested_ptr_string_ptr_val","bool":true,"bool_ptr":true}}
+			]`,
+		},
+		"remove": {
+			first: testData(nil),
+			second: testData(func(t test) test {
+				t.BoolPtr = nil
+				t.Nested.StringPtr = nil
+				return t
+			}),
+			wantOps: `[
+				{"op":"remove","path":"/bool_ptr"},
+				{"op":"remove","path":"/nested/string_ptr"}
+			]`,
+		},
+		"keys": {
+			first: testData(nil),
+			second: testData(func(t test) test {
+				t.String = "test2"
+				t.Bool = false
+				return t
+			}),
+			keys: map[string]interface{}{
+				"bool": nil,
+			},
+			wantOps: `[
+				{"op":"replace","path":"/bool","value":false}
+			]`,
+		},
+	} {
+		gotOps, err := DiffPatch(&data.first, &data.second, data.keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		gotJSON, err := json.Marshal(gotOps)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		var got, want interface{}
+		err = json.Unmarshal(gotJSON, &got)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		err = json.Unmarshal([]byte(data.wantOps), &want)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		if diff := pretty.Diff(want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, pretty.Diff(want, got))
+		}
+	}
+}
+
+func TestPatchOperationMarshal(t *testing.T) {
+	for want, op := range map[string]PatchOperation{
+		`{"op":"replace","path":"/any","value":null}`:  {Op: PatchOpReplace, Path: "/any"},
+		`{"op":"add","path":"/any","value":"string"}`:  {Op: PatchOpAdd, Path: "/any", Value: "string"},
+		`{"op":"test","path":"/any","value":null}`:     {Op: PatchOpTest, Path: "/any"},
+		`{"op":"remove","path":"/any"}`:                {Op: PatchOpRemove, Path: "/any"},
+		`{"op":"move","path":"/any","from":"/string"}`: {Op: PatchOpMove, Path: "/any", From: "/string"},
+	} {
+		got, err := json.Marshal(op)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if string(got) != want {
+			t.Errorf("%v: got %s", want, got)
+		}
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	for want, path := range map[string][]string{
+		"":           nil,
+		"/a/b":       {"a", "b"},
+		"/a~1b/c~0d": {"a/b", "c~d"},
+	} {
+		if got := jsonPointer(path); got != want {
+			t.Errorf("%v: got %v", want, got)
+		}
+	}
+}
+
+type patchTest struct {
+	test
+	Strings []string          `json:"strings"`
+	Labels  map[string]string `json:"labels"`
+	Items   []Nested          `json:"items"`
+}
+
+func patchTestData(modify func(patchTest) patchTest) patchTest {
+	data := patchTest{
+		test:    testData(nil),
+		Strings: []string{"a", "b"},
+		Labels:  map[string]string{"env": "prod", "a/b": "c"},
+		Items:   []Nested{{String: "item1"}, {String: "item2"}},
+	}
+
+	if modify != nil {
+		data = modify(data)
+	}
+
+	return data
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		current          patchTest
+		ops              string
+		want             patchTest
+		wantAffectedKeys []string
+	}{
+		"replace": {
+			current: patchTestData(nil),
+			ops:     `[{"op":"replace","path":"/string","value":"test2"},{"op":"replace","path":"/nested_ptr/bool_ptr","value":false}]`,
+			want: patchTestData(func(t patchTest) patchTest {
+				t.String = "test2"
+				t.NestedPtr.BoolPtr = boolPtr(false)
+				return t
+			}),
+			wantAffectedKeys: []string{"string", "nested_ptr.bool_ptr"},
+		},
+		"add": {
+			current: patchTestData(func(t patchTest) patchTest {
+				t.NestedPtr = nil
+				return t
+			}),
+			ops: `[
+				{"op":"add","path":"/nested_ptr","value":{"string":"test2"}},
+				{"op":"add","path":"/strings/1","value":"c"},
+				{"op":"add","path":"/strings/-","value":"d"},
+				{"op":"add","path":"/labels/team","value":"core"}
+			]`,
+			want: patchTestData(func(t patchTest) patchTest {
+				t.NestedPtr = &Nested{String: "test2"}
+				t.Strings = []string{"a", "c", "b", "d"}
+				t.Labels["team"] = "core"
+				return t
+			}),
+			wantAffectedKeys: []string{"nested_ptr", "strings.1", "strings.-", "labels.team"},
+		},
+		"remove": {
+			current: patchTestData(nil),
+			ops: `[
+				{"op":"remove","path":"/anonym_ptr2_nested_ptr"},
+				{"op":"remove","path":"/strings/0"},
+				{"op":"remove","path":"/labels/a~1b"}
+			]`,
+			want: patchTestData(func(t patchTest) patchTest {
+				t.AnonymPtr2NestedPtr = nil
+				t.Strings = []string{"b"}
+				delete(t.Labels, "a/b")
+				return t
+			}),
+			wantAffectedKeys: []string{"anonym_ptr2_nested_ptr", "strings.0", "labels.a/b"},
+		},
+		"test": {
+			current:          patchTestData(nil),
+			ops:              `[{"op":"test","path":"/nested","value":{"string":"nested_string_val","string_ptr":"nested_string_ptr_val","bool":true,"bool_ptr":true}}]`,
+			want:             patchTestData(nil),
+			wantAffectedKeys: []string{},
+		},
+		"copy": {
+			current: patchTestData(nil),
+			ops:     `[{"op":"copy","from":"/items/0","path":"/items/-"},{"op":"copy","from":"/string","path":"/anonym_string"}]`,
+			want: patchTestData(func(t patchTest) patchTest {
+				t.Items = append(t.Items, Nested{String: "item1"})
+				t.AnonymString = "string_val"
+				return t
+			}),
+			wantAffectedKeys: []string{"items.-", "anonym_string"},
+		},
+		"move": {
+			current: patchTestData(nil),
+			ops:     `[{"op":"move","from":"/items/0","path":"/items/1"},{"op":"move","from":"/string_ptr","path":"/anonym_string_ptr"}]`,
+			want: patchTestData(func(t patchTest) patchTest {
+				t.Items = []Nested{{String: "item2"}, {String: "item1"}}
+				t.AnonymStringPtr = t.StringPtr
+				t.StringPtr = nil
+				return t
+			}),
+			wantAffectedKeys: []string{"items.0", "items.1", "string_ptr", "anonym_string_ptr"},
+		},
+	} {
+		got := data.current
+		gotAffectedKeys, err := ApplyJSONPatch(&got, []byte(data.ops))
+		if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+
+		if diff := pretty.Diff(data.want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, pretty.Diff(data.want, got))
+		}
+
+		if diff := pretty.Diff(data.wantAffectedKeys, gotAffectedKeys); len(diff) > 0 {
+			t.Errorf("%v affectedKeys: diffs (want/got): %v", name, pretty.Diff(data.wantAffectedKeys, gotAffectedKeys))
+		}
+	}
+}
+
+func TestApplyJSONPatchAtomic(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		ops          string
+		wantTestFail bool
+	}{
+		"test_failed": {
+			ops:          `[{"op":"replace","path":"/nested_ptr/string","value":"test2"},{"op":"test","path":"/string","value":"test2"}]`,
+			wantTestFail: true,
+		},
+		"path_not_found": {
+			ops: `[{"op":"add","path":"/labels/team","value":"core"},{"op":"replace","path":"/unknown","value":"test2"}]`,
+		},
+		"index_out_of_range": {
+			ops: `[{"op":"remove","path":"/strings/0"},{"op":"replace","path":"/strings/1","value":"test2"}]`,
+		},
+		"invalid_value": {
+			ops: `[{"op":"replace","path":"/anonym_ptr2_string","value":"test2"},{"op":"replace","path":"/bool","value":"test2"}]`,
+		},
+		"unknown_op": {
+			ops: `[{"op":"replace","path":"/items/0/string","value":"test2"},{"op":"unknown","path":"/string"}]`,
+		},
+	} {
+		got := patchTestData(nil)
+		_, err := ApplyJSONPatch(&got, []byte(data.ops))
+		if err == nil {
+			t.Fatalf("%v: expected error", name)
+		}
+
+		if _, ok := err.(*PatchTestError); ok != data.wantTestFail {
+			t.Errorf("%v: unexpected error: %v", name, err)
+		}
+
+		if diff := pretty.Diff(patchTestData(nil), got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, pretty.Diff(patchTestData(nil), got))
+		}
+	}
+}
+
+func TestApplyJSONPatchUntouched(t *testing.T) {
+	got := patchTestData(nil)
+	nestedPtr := got.NestedPtr
+	labels := got.Labels
+	items := got.Items
+
+	_, err := ApplyJSONPatch(&got, []byte(`[{"op":"replace","path":"/string","value":"test2"},{"op":"add","path":"/items/-","value":{"string":"item3"}}]`))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	if got.NestedPtr != nestedPtr {
+		t.Errorf("untouched nested_ptr was reallocated")
+	}
+	got.Labels["team"] = "core"
+	if labels["team"] != "core" {
+		t.Errorf("untouched labels were reallocated")
+	}
+	if &items[0] == &got.Items[0] {
+		t.Errorf("patched items are shared with the previous value")
+	}
+}
@@ -0,0 +1,20 @@
+package shallow
+
+// ChangeListener is notified of the changes made by Merge (see WithListener).
+type ChangeListener interface {
+	// BeginMerge is called when Merge starts, before changing dest.
+	BeginMerge(dest interface{})
+	// OnChange is called for every change made by Merge, in the same order as the returned keys.
+	// It is called after all the changes were made, and only if Merge succeeds.
+	OnChange(change Change)
+	// EndMerge is called when Merge returns, with the returned error.
+	EndMerge(dest interface{}, err error)
+}
+
+// WithListener can be used to observe the changes made by Merge, and by the functions built on it (e.g. MergePatchJSON).
+// Merges of copies (MergePreview and Merge3) don't notify the listener.
+func WithListener(listener ChangeListener) Option {
+	return func(o *options) {
+		o.listener = listener
+	}
+}
@@ -0,0 +1,75 @@
+package shallow
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+type recordingListener struct {
+	events []string
+}
+
+func (l *recordingListener) BeginMerge(dest interface{}) {
+	l.events = append(l.events, fmt.Sprintf("begin %T", dest))
+}
+
+func (l *recordingListener) OnChange(change Change) {
+	l.events = append(l.events, fmt.Sprintf("change %v %v: %v -> %v", change.Kind, change.Key, change.Old, change.New))
+}
+
+func (l *recordingListener) EndMerge(dest interface{}, err error) {
+	l.events = append(l.events, fmt.Sprintf("end %T %v", dest, err))
+}
+
+func TestMergeListener(t *testing.T) {
+	got := testData(nil)
+	update := testData(func(t test) test {
+		t.String = "test2"
+		t.NestedPtr.Bool = false
+		return t
+	})
+	keys := map[string]interface{}{
+		"string":     nil,
+		"bool":       nil,
+		"nested_ptr": map[string]interface{}{"bool": nil},
+	}
+
+	listener := &recordingListener{}
+	_, err := Merge(&got, &update, keys, Deep(), WithListener(listener))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	_, _, err = MergePreview(&got, &update, map[string]interface{}{"bool_ptr": nil}, WithListener(listener))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	validationErr := errors.New("invalid")
+	_, err = Merge(&got, &Nested{}, nil, WithListener(listener))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	update.BoolPtr = nil
+	_, err = Merge(&got, &update, map[string]interface{}{"bool_ptr": nil}, WithListener(listener), WithValidation(func(dest interface{}) error {
+		return validationErr
+	}))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	want := []string{
+		"begin *shallow.test",
+		"change modified string: string_val -> test2",
+		"change modified nested_ptr.bool: true -> false",
+		"end *shallow.test <nil>",
+		"begin *shallow.test",
+		"end *shallow.test " + err.Error(),
+	}
+	if diff := pretty.Diff(want, listener.events); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", diff)
+	}
+}
@@ -0,0 +1,134 @@
+package shallow
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ConflictPolicy selects how Merge3 resolves conflicts.
+type ConflictPolicy int
+
+const (
+	// ConflictPolicyError makes Merge3 return a *ConflictError if there are any conflicts.
+	ConflictPolicyError ConflictPolicy = iota
+	// ConflictPolicyOurs resolves conflicts by keeping the values of ours.
+	ConflictPolicyOurs
+	// ConflictPolicyTheirs resolves conflicts by using the values of theirs.
+	ConflictPolicyTheirs
+)
+
+// Conflict describes a field changed differently by ours and theirs (see Merge3).
+// The keys of the two changes are either the same, or one of them is nested within the other.
+type Conflict struct {
+	// Ours is the change from base to ours.
+	Ours Change
+	// Theirs is the change from base to theirs.
+	Theirs Change
+}
+
+// ConflictError is returned by Merge3 with the ConflictPolicyError policy if there are any conflicts.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	keys := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		keys = append(keys, c.Theirs.Key)
+	}
+
+	return fmt.Sprintf("conflicting changes: %v", strings.Join(keys, ", "))
+}
+
+// WithConflictPolicy can be used to select how Merge3 resolves conflicts, the default is ConflictPolicyError.
+func WithConflictPolicy(policy ConflictPolicy) Option {
+	return func(o *options) {
+		o.conflictPolicy = policy
+	}
+}
+
+// Merge3 merges the changes of ours and theirs, both made to the base struct. The changes are compared field by field
+// the same way as Diff with the Deep option, e.g. Diff(base, ours, nil, Deep()), except that the values of fields
+// with a merge strategy (see StrategyTag) are compared and merged as a whole, as both ours and theirs already
+// contain the base value.
+//
+// Fields changed only by theirs are merged into a copy of ours, which is returned with the list of conflicts.
+// Fields changed by both ours and theirs to the same value are not conflicts. Conflicts are resolved according
+// to the conflict policy (see WithConflictPolicy), with ConflictPolicyError a *ConflictError is returned.
+//
+// Base, ours and theirs must be a pointer to a non-nil struct of the same type, none of them is changed.
+// The returned merged struct is a pointer of the same type. Accepts the same options as Merge, except for WithListener.
+func Merge3(base interface{}, ours interface{}, theirs interface{}, opts ...Option) (merged interface{}, conflicts []Conflict, err error) {
+	opts = withOptions(opts, Deep(), replacingValues())
+	oursChanges, err := processChanges(base, ours, nil, false, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	theirsChanges, err := processChanges(base, theirs, nil, false, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	o := newOptions(opts)
+	theirsV := reflect.New(reflect.TypeOf(theirs).Elem())
+	theirsV.Elem().Set(deepCopy(reflect.ValueOf(theirs).Elem()))
+	keys := make(map[string]interface{})
+	conflicts = make([]Conflict, 0)
+	for _, tc := range theirsChanges {
+		conflicting := false
+		for _, oc := range oursChanges {
+			if !overlaps(oc.path, tc.path) {
+				continue
+			}
+			if oc.key() == tc.key() && oc.op == tc.op && o.equal(oc.newValue, tc.newValue, equalMethod(tc.newValue.Type())) {
+				continue
+			}
+
+			conflicting = true
+			conflicts = append(conflicts, Conflict{
+				Ours:   oc.export(),
+				Theirs: tc.export(),
+			})
+		}
+
+		if !conflicting || o.conflictPolicy == ConflictPolicyTheirs {
+			o.addPathKeys(keys, theirsV.Elem(), tc.path.steps)
+		}
+	}
+	if len(conflicts) > 0 && o.conflictPolicy == ConflictPolicyError {
+		return nil, conflicts, &ConflictError{Conflicts: conflicts}
+	}
+
+	mergedV := reflect.New(theirsV.Type().Elem())
+	mergedV.Elem().Set(deepCopy(reflect.ValueOf(ours).Elem()))
+	_, err = Merge(mergedV.Interface(), theirsV.Interface(), keys, withOptions(opts, WithListener(nil))...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return mergedV.Interface(), conflicts, nil
+}
+
+// replacingValues makes Diff and Merge use the source values as they are, ignoring the merge strategies.
+func replacingValues() Option {
+	return func(o *options) {
+		o.replaceValues = true
+	}
+}
+
+// overlaps checks if the a and b paths are the same, or one of them is nested within the other.
+func overlaps(a fieldPath, b fieldPath) bool {
+	n := len(a.steps)
+	if len(b.steps) < n {
+		n = len(b.steps)
+	}
+
+	for i := 0; i < n; i++ {
+		if a.steps[i].String() != b.steps[i].String() {
+			return false
+		}
+	}
+
+	return true
+}
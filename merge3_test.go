@@ -0,0 +1,159 @@
+package shallow
+
+import (
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+func TestMerge3(t *testing.T) {
+	base := testData(nil)
+	ours := testData(func(t test) test {
+		t.String = "ours"
+		t.Bool = false
+		t.NestedPtr.String = "ours"
+		t.Nested = Nested{}
+		return t
+	})
+	theirs := testData(func(t test) test {
+		t.Bool = false
+		t.StringPtr = stringPtr("theirs")
+		t.NestedPtr.Bool = false
+		t.Nested.String = "theirs"
+		t.AnonymString = "theirs"
+		return t
+	})
+
+	for name, data := range map[string]struct {
+		policy        ConflictPolicy
+		want          test
+		wantConflicts []string
+	}{
+		"ours": {
+			policy: ConflictPolicyOurs,
+			want: testData(func(t test) test {
+				t.String = "ours"
+				t.Bool = false
+				t.StringPtr = stringPtr("theirs")
+				t.NestedPtr.String = "ours"
+				t.NestedPtr.Bool = false
+				t.Nested = Nested{}
+				t.AnonymString = "theirs"
+				return t
+			}),
+			wantConflicts: []string{"nested.string"},
+		},
+		"theirs": {
+			policy: ConflictPolicyTheirs,
+			want: testData(func(t test) test {
+				t.String = "ours"
+				t.Bool = false
+				t.StringPtr = stringPtr("theirs")
+				t.NestedPtr.String = "ours"
+				t.NestedPtr.Bool = false
+				t.Nested = Nested{String: "theirs"}
+				t.AnonymString = "theirs"
+				return t
+			}),
+			wantConflicts: []string{"nested.string"},
+		},
+	} {
+		merged, conflicts, err := Merge3(&base, &ours, &theirs, WithConflictPolicy(data.policy))
+		if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+
+		if diff := pretty.Diff(&data.want, merged); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+
+		gotConflicts := make([]string, 0, len(conflicts))
+		for _, c := range conflicts {
+			gotConflicts = append(gotConflicts, c.Ours.Key)
+		}
+		if diff := pretty.Diff(data.wantConflicts, gotConflicts); len(diff) > 0 {
+			t.Errorf("%v conflicts: diffs (want/got): %v", name, diff)
+		}
+	}
+
+	if diff := pretty.Diff(testData(nil), base); len(diff) > 0 {
+		t.Errorf("base modified: %v", diff)
+	}
+}
+
+func TestMerge3Strategy(t *testing.T) {
+	base := strategyTestData(nil)
+	ours := strategyTestData(func(t strategyTest) strategyTest {
+		t.Roles = []string{"admin", "dev"}
+		return t
+	})
+	theirs := strategyTestData(func(t strategyTest) strategyTest {
+		t.Tags = []string{"a", "b"}
+		t.Labels = map[string]string{"team": "core"}
+		t.Owner = "theirs"
+		return t
+	})
+
+	merged, conflicts, err := Merge3(&base, &ours, &theirs)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	want := strategyTestData(func(t strategyTest) strategyTest {
+		t.Tags = []string{"a", "b"}
+		t.Roles = []string{"admin", "dev"}
+		t.Labels = map[string]string{"team": "core"}
+		t.Owner = "theirs"
+		return t
+	})
+	if diff := pretty.Diff(&want, merged); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", diff)
+	}
+	if len(conflicts) > 0 {
+		t.Errorf("unexpected conflicts: %v", conflicts)
+	}
+}
+
+func TestMerge3Error(t *testing.T) {
+	base := testData(nil)
+	ours := testData(func(t test) test {
+		t.NestedPtr = nil
+		return t
+	})
+	theirs := testData(func(t test) test {
+		t.NestedPtr.Bool = false
+		return t
+	})
+
+	_, conflicts, err := Merge3(&base, &ours, &theirs)
+	conflictErr, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("expected *ConflictError, got: %v", err)
+	}
+
+	want := []Conflict{
+		{
+			Ours: Change{
+				Key:  "nested_ptr",
+				Path: []string{"nested_ptr"},
+				Old:  base.NestedPtr,
+				New:  (*Nested)(nil),
+				Kind: ChangeCleared,
+			},
+			Theirs: Change{
+				Key:  "nested_ptr.bool",
+				Path: []string{"nested_ptr", "bool"},
+				Old:  true,
+				New:  false,
+				Kind: ChangeModified,
+			},
+		},
+	}
+	if diff := pretty.Diff(want, conflicts); len(diff) > 0 {
+		t.Errorf("conflicts: diffs (want/got): %v", diff)
+	}
+	if diff := pretty.Diff(want, conflictErr.Conflicts); len(diff) > 0 {
+		t.Errorf("error conflicts: diffs (want/got): %v", diff)
+	}
+}
@@ -0,0 +1,262 @@
+package shallow
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/proemergotech/errors/v2"
+)
+
+// ConversionError is returned by MergeMap if a value cannot be converted to the type of its field.
+type ConversionError struct {
+	// Key of the value, with keys within nested values returned as dotted paths.
+	Key   string
+	Type  reflect.Type
+	Value interface{}
+	Err   error
+}
+
+func (e *ConversionError) Error() string {
+	msg := fmt.Sprintf("cannot convert value %#v of key %q to %v", e.Value, e.Key, e.Type)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+
+	return msg
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// MergeMap merges the update map into the dest struct, the same way as Merge would merge a struct decoded from the
+// update map, using the update map as keys.
+//
+// Dest must be a non-nil pointer to a struct. Keys of the update map are resolved to fields by their tag
+// (specified by tag option, default "json"), and values are converted to the type of the field:
+//   - nil values are converted to the zero value,
+//   - numbers are converted between numeric types if they fit, and to integer types only if they are integral
+//     (e.g. float64 to int),
+//   - strings are converted to types implementing encoding.TextUnmarshaler (e.g. time.Time),
+//   - maps are converted to structs and maps, and slices to slices and arrays, element by element,
+//   - pointers are allocated for non-nil values.
+//
+// Keys not matching any field are ignored. With the Deep option, nested maps are merged into struct fields
+// instead of replacing them.
+//
+// If any of the values cannot be converted, a *ConversionError is returned, and dest is left untouched.
+//
+// Returns with a list of updated keys.
+func MergeMap(dest interface{}, update map[string]interface{}, opts ...Option) (updatedKeys []string, err error) {
+	err = checkStructPointer(dest)
+	if err != nil {
+		return nil, err
+	}
+	destV := reflect.ValueOf(dest)
+
+	o := newOptions(opts)
+
+	_, err = getPlan(destV.Type().Elem(), o.tag)
+	if err != nil {
+		return nil, err
+	}
+
+	updateV := reflect.New(destV.Type().Elem())
+	err = convertStruct(updateV.Elem(), update, o.tag, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return Merge(dest, updateV.Interface(), update, opts...)
+}
+
+func convertStruct(targetV reflect.Value, values map[string]interface{}, tag fieldTag, prefix string) error {
+	for key, value := range values {
+		fieldV, ok := lookupField(targetV, tag, key, true)
+		if !ok {
+			continue
+		}
+
+		err := convertValue(fieldV, value, tag, prefix+key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// convertValue converts value to the type of targetV, and sets it.
+func convertValue(targetV reflect.Value, value interface{}, tag fieldTag, key string) error {
+	if value == nil {
+		targetV.Set(reflect.Zero(targetV.Type()))
+		return nil
+	}
+
+	t := targetV.Type()
+	valueV := reflect.ValueOf(value)
+	if valueV.Type().AssignableTo(t) {
+		targetV.Set(valueV)
+		return nil
+	}
+
+	convErr := func(err error) error {
+		return &ConversionError{Key: key, Type: t, Value: value, Err: err}
+	}
+
+	if str, ok := value.(string); ok && reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		newV := reflect.New(t)
+		err := newV.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str))
+		if err != nil {
+			return convErr(err)
+		}
+		targetV.Set(newV.Elem())
+
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		newV := reflect.New(t.Elem())
+		err := convertValue(newV.Elem(), value, tag, key)
+		if err != nil {
+			return err
+		}
+		targetV.Set(newV)
+
+		return nil
+
+	case reflect.Interface:
+		if valueV.Type().Implements(t) {
+			targetV.Set(valueV)
+			return nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toInt(valueV)
+		if ok && !targetV.OverflowInt(i) {
+			targetV.SetInt(i)
+			return nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := toInt(valueV)
+		if ok && i >= 0 && !targetV.OverflowUint(uint64(i)) {
+			targetV.SetUint(uint64(i))
+			return nil
+		}
+		if valueV.Kind() >= reflect.Uint && valueV.Kind() <= reflect.Uintptr && !targetV.OverflowUint(valueV.Uint()) {
+			targetV.SetUint(valueV.Uint())
+			return nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat(valueV)
+		if ok && !targetV.OverflowFloat(f) {
+			targetV.SetFloat(f)
+			return nil
+		}
+
+	case reflect.String, reflect.Bool:
+		if valueV.Kind() == t.Kind() {
+			targetV.Set(valueV.Convert(t))
+			return nil
+		}
+
+	case reflect.Struct:
+		if values, ok := value.(map[string]interface{}); ok {
+			newV := reflect.New(t).Elem()
+			err := convertStruct(newV, values, tag, key+".")
+			if err != nil {
+				return err
+			}
+			targetV.Set(newV)
+
+			return nil
+		}
+
+	case reflect.Map:
+		if values, ok := value.(map[string]interface{}); ok && t.Key().Kind() == reflect.String {
+			newV := reflect.MakeMapWithSize(t, len(values))
+			for k, v := range values {
+				elemV := reflect.New(t.Elem()).Elem()
+				err := convertValue(elemV, v, tag, key+"."+k)
+				if err != nil {
+					return err
+				}
+				newV.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), elemV)
+			}
+			targetV.Set(newV)
+
+			return nil
+		}
+
+	case reflect.Slice, reflect.Array:
+		if values, ok := value.([]interface{}); ok {
+			var newV reflect.Value
+			if t.Kind() == reflect.Slice {
+				newV = reflect.MakeSlice(t, len(values), len(values))
+			} else if len(values) <= t.Len() {
+				newV = reflect.New(t).Elem()
+			} else {
+				return convErr(errors.Errorf("array of length %d cannot hold %d values", t.Len(), len(values)))
+			}
+			for i, v := range values {
+				err := convertValue(newV.Index(i), v, tag, key+"."+strconv.Itoa(i))
+				if err != nil {
+					return err
+				}
+			}
+			targetV.Set(newV)
+
+			return nil
+		}
+	}
+
+	return convErr(nil)
+}
+
+// toInt returns the value of a number as an int64, if it can be represented without losing precision.
+func toInt(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(v.Uint()), v.Uint() <= math.MaxInt64
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		return int64(f), f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64
+	case reflect.String:
+		if n, ok := v.Interface().(json.Number); ok {
+			i, err := n.Int64()
+			return i, err == nil
+		}
+	}
+
+	return 0, false
+}
+
+// toFloat returns the value of a number as a float64.
+func toFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		if n, ok := v.Interface().(json.Number); ok {
+			f, err := n.Float64()
+			return f, err == nil
+		}
+	}
+
+	return 0, false
+}
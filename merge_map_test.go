@@ -0,0 +1,218 @@
+package shallow
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+type status string
+
+type mapTest struct {
+	Int      int               `json:"int"`
+	Uint8    uint8             `json:"uint8"`
+	Float32  float32           `json:"float32"`
+	Status   status            `json:"status"`
+	Time     time.Time         `json:"time"`
+	TimePtr  *time.Time        `json:"time_ptr"`
+	Ints     []int             `json:"ints"`
+	Array    [2]string         `json:"array"`
+	Counts   map[string]int    `json:"counts"`
+	Nested   Nested            `json:"nested"`
+	Nesteds  []*Nested         `json:"nesteds"`
+	Any      interface{}       `json:"any"`
+	Labels   map[string]string `json:"labels"`
+	Untagged string
+	*AnonymPtr
+}
+
+func mapTestData(modify func(mapTest) mapTest) mapTest {
+	data := mapTest{
+		Int:     1,
+		Uint8:   2,
+		Float32: 3.5,
+		Status:  "active",
+		Time:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Ints:    []int{1, 2},
+		Array:   [2]string{"a", "b"},
+		Counts:  map[string]int{"a": 1},
+		Nested: Nested{
+			String: "nested_string_val",
+			Bool:   true,
+		},
+		Labels:   map[string]string{"env": "prod"},
+		Untagged: "untagged_val",
+	}
+
+	if modify != nil {
+		data = modify(data)
+	}
+
+	return data
+}
+
+func TestMergeMap(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		current         mapTest
+		incoming        string
+		opts            []Option
+		want            mapTest
+		wantChangedKeys []string
+	}{
+		"numbers": {
+			current:  mapTestData(nil),
+			incoming: `{"int":-5,"uint8":255,"float32":1}`,
+			want: mapTestData(func(t mapTest) mapTest {
+				t.Int = -5
+				t.Uint8 = 255
+				t.Float32 = 1
+				return t
+			}),
+			wantChangedKeys: []string{"int", "uint8", "float32"},
+		},
+		"same": {
+			current:         mapTestData(nil),
+			incoming:        `{"int":1,"status":"active","time":"2020-01-02T03:04:05Z","ints":[1,2],"nested":{"string":"nested_string_val","bool":true}}`,
+			want:            mapTestData(nil),
+			wantChangedKeys: []string{},
+		},
+		"text_unmarshaler": {
+			current:  mapTestData(nil),
+			incoming: `{"status":"inactive","time":"2021-01-02T03:04:05Z","time_ptr":"2022-01-02T03:04:05Z"}`,
+			want: mapTestData(func(t mapTest) mapTest {
+				t.Status = "inactive"
+				t.Time = time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+				timePtr := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+				t.TimePtr = &timePtr
+				return t
+			}),
+			wantChangedKeys: []string{"status", "time", "time_ptr"},
+		},
+		"containers": {
+			current:  mapTestData(nil),
+			incoming: `{"ints":[3],"array":["c"],"counts":{"b":2},"nesteds":[{"bool":true},null],"any":{"a":1},"labels":null}`,
+			want: mapTestData(func(t mapTest) mapTest {
+				t.Ints = []int{3}
+				t.Array = [2]string{"c", ""}
+				t.Counts = map[string]int{"b": 2}
+				t.Nesteds = []*Nested{{Bool: true}, nil}
+				t.Any = map[string]interface{}{"a": float64(1)}
+				t.Labels = nil
+				return t
+			}),
+			wantChangedKeys: []string{"ints", "array", "counts", "nesteds", "any", "labels"},
+		},
+		"nested": {
+			current:  mapTestData(nil),
+			incoming: `{"nested":{"string_ptr":"test2"}}`,
+			want: mapTestData(func(t mapTest) mapTest {
+				t.Nested = Nested{StringPtr: stringPtr("test2")}
+				return t
+			}),
+			wantChangedKeys: []string{"nested"},
+		},
+		"nested_deep": {
+			current:  mapTestData(nil),
+			incoming: `{"nested":{"string_ptr":"test2"}}`,
+			opts:     []Option{Deep()},
+			want: mapTestData(func(t mapTest) mapTest {
+				t.Nested.StringPtr = stringPtr("test2")
+				return t
+			}),
+			wantChangedKeys: []string{"nested.string_ptr"},
+		},
+		"anonym_ptr": {
+			current:  mapTestData(nil),
+			incoming: `{"anonym_ptr2_bool":true,"unknown":1}`,
+			want: mapTestData(func(t mapTest) mapTest {
+				t.AnonymPtr = &AnonymPtr{AnonymPtr2: &AnonymPtr2{AnonymPtr2Bool: true}}
+				return t
+			}),
+			wantChangedKeys: []string{"anonym_ptr2_bool"},
+		},
+	} {
+		var update map[string]interface{}
+		err := json.Unmarshal([]byte(data.incoming), &update)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		got := data.current
+		gotChangedKeys, err := MergeMap(&got, update, data.opts...)
+		if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+
+		if diff := pretty.Diff(data.want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, diff)
+		}
+	}
+}
+
+func TestMergeMapConversionError(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		update  map[string]interface{}
+		wantKey string
+	}{
+		"fraction": {
+			update:  map[string]interface{}{"int": 1.5},
+			wantKey: "int",
+		},
+		"overflow": {
+			update:  map[string]interface{}{"uint8": float64(256)},
+			wantKey: "uint8",
+		},
+		"negative": {
+			update:  map[string]interface{}{"uint8": float64(-1)},
+			wantKey: "uint8",
+		},
+		"text_unmarshaler": {
+			update:  map[string]interface{}{"time_ptr": "yesterday"},
+			wantKey: "time_ptr",
+		},
+		"type": {
+			update:  map[string]interface{}{"status": true},
+			wantKey: "status",
+		},
+		"slice_element": {
+			update:  map[string]interface{}{"ints": []interface{}{float64(1), "2"}},
+			wantKey: "ints.1",
+		},
+		"array_length": {
+			update:  map[string]interface{}{"array": []interface{}{"a", "b", "c"}},
+			wantKey: "array",
+		},
+		"nested": {
+			update:  map[string]interface{}{"nested": map[string]interface{}{"bool": "true"}},
+			wantKey: "nested.bool",
+		},
+		"map_element": {
+			update:  map[string]interface{}{"counts": map[string]interface{}{"a": "b"}},
+			wantKey: "counts.a",
+		},
+	} {
+		got := mapTestData(nil)
+		_, err := MergeMap(&got, data.update)
+		convErr, ok := err.(*ConversionError)
+		if !ok {
+			t.Errorf("%v: expected conversion error, got: %v", name, err)
+			continue
+		}
+		if convErr.Key != data.wantKey {
+			t.Errorf("%v: want key %q, got %q", name, data.wantKey, convErr.Key)
+		}
+
+		if diff := pretty.Diff(mapTestData(nil), got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+	}
+}
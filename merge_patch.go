@@ -0,0 +1,115 @@
+package shallow
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/proemergotech/errors/v2"
+)
+
+// MergePatchJSON applies a JSON merge patch (RFC 7396) to the dest struct.
+//
+// Dest must be a non-nil pointer to a struct, and patch must be a JSON object. Keys of the patch are resolved
+// to fields by their tag (specified by tag option, default "json"), the same way as in Merge:
+//   - null values set the field to its zero value,
+//   - objects given for struct or struct pointer fields are merged recursively,
+//   - objects given for map fields with string keys are merged entry by entry, null entries are deleted
+//     (see MergeMaps),
+//   - all other values (including arrays) replace the field value, and are decoded by encoding/json.
+//
+// Keys not matching any field are ignored.
+//
+// Returns with a list of updated keys, with keys within nested fields returned as dotted paths (see Deep).
+func MergePatchJSON(dest interface{}, patch []byte, opts ...Option) (updatedKeys []string, err error) {
+	err = checkStructPointer(dest)
+	if err != nil {
+		return nil, err
+	}
+	destV := reflect.ValueOf(dest)
+
+	o := newOptions(opts)
+
+	_, err = getPlan(destV.Type().Elem(), o.tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys map[string]interface{}
+	err = json.Unmarshal(patch, &keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "patch must be a JSON object")
+	}
+	if keys == nil {
+		return nil, errors.New("patch must be a JSON object")
+	}
+
+	// decode over a copy of dest, so objects are merged into the current values of fields processed as a whole
+	// (e.g. struct fields having an Equal method)
+	updateV := reflect.New(destV.Elem().Type())
+	updateV.Elem().Set(deepCopy(destV.Elem()))
+	err = decodeMergePatch(updateV.Elem(), patch, o.tag, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return Merge(dest, updateV.Interface(), keys, withOptions(opts, Deep(), MergeMaps())...)
+}
+
+// decodeMergePatch decodes the patch object into the targetV struct, resolving fields by the given tag.
+func decodeMergePatch(targetV reflect.Value, patch []byte, tag fieldTag, prefix string) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(patch, &fields)
+	if err != nil {
+		return errors.Wrapf(err, "cannot decode patch object at %q", strings.TrimSuffix(prefix, "."))
+	}
+
+	for key, raw := range fields {
+		fieldV, ok := lookupField(targetV, tag, key, true)
+		if !ok {
+			continue
+		}
+
+		err = decodeValue(fieldV, raw, tag, prefix+key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeValue decodes the raw JSON value into targetV. Objects given for struct or struct pointer values are
+// decoded by resolving fields with the given tag, merged into the current value of targetV. Everything else is
+// decoded by encoding/json into a new value replacing targetV, as encoding/json would reuse the elements of the
+// current slices and arrays.
+func decodeValue(targetV reflect.Value, raw json.RawMessage, tag fieldTag, key string) error {
+	raw = bytes.TrimSpace(raw)
+	if bytes.Equal(raw, []byte("null")) {
+		targetV.Set(reflect.Zero(targetV.Type()))
+		return nil
+	}
+
+	if len(raw) > 0 && raw[0] == '{' {
+		structV := targetV
+		if structV.Kind() == reflect.Ptr && structV.Type().Elem().Kind() == reflect.Struct {
+			if structV.IsNil() {
+				structV.Set(reflect.New(structV.Type().Elem()))
+			}
+			structV = structV.Elem()
+		}
+		if structV.Kind() == reflect.Struct {
+			return decodeMergePatch(structV, raw, tag, key+".")
+		}
+	}
+
+	newV := reflect.New(targetV.Type())
+	err := json.Unmarshal(raw, newV.Interface())
+	if err != nil {
+		return errors.Wrapf(err, "cannot decode patch value for key %q", key)
+	}
+	targetV.Set(newV.Elem())
+
+	return nil
+}
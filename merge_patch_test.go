@@ -0,0 +1,242 @@
+package shallow
+
+import (
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+func TestMergePatchJSON(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		current         test
+		patch           string
+		want            test
+		wantChangedKeys []string
+	}{
+		"string_change": {
+			current: testData(nil),
+			patch:   `{"string":"test2"}`,
+			want: testData(func(t test) test {
+				t.String = "test2"
+				return t
+			}),
+			wantChangedKeys: []string{"string"},
+		},
+		"string_ptr_null": {
+			current: testData(nil),
+			patch:   `{"string_ptr":null}`,
+			want: testData(func(t test) test {
+				t.StringPtr = nil
+				return t
+			}),
+			wantChangedKeys: []string{"string_ptr"},
+		},
+		"unknown_key": {
+			current:         testData(nil),
+			patch:           `{"unknown":"test2"}`,
+			want:            testData(nil),
+			wantChangedKeys: []string{},
+		},
+		"nested_change": {
+			current: testData(nil),
+			patch:   `{"nested":{"string":"test2","bool_ptr":null}}`,
+			want: testData(func(t test) test {
+				t.Nested.String = "test2"
+				t.Nested.BoolPtr = nil
+				return t
+			}),
+			wantChangedKeys: []string{"nested.string", "nested.bool_ptr"},
+		},
+		"nested_null": {
+			current: testData(nil),
+			patch:   `{"nested":null}`,
+			want: testData(func(t test) test {
+				t.Nested = Nested{}
+				return t
+			}),
+			wantChangedKeys: []string{"nested"},
+		},
+		"nested_ptr_null": {
+			current: testData(nil),
+			patch:   `{"nested_ptr":null}`,
+			want: testData(func(t test) test {
+				t.NestedPtr = nil
+				return t
+			}),
+			wantChangedKeys: []string{"nested_ptr"},
+		},
+		"nested_ptr_nil_dest": {
+			current: testData(func(t test) test {
+				t.NestedPtr = nil
+				return t
+			}),
+			patch: `{"nested_ptr":{"bool":true}}`,
+			want: testData(func(t test) test {
+				t.NestedPtr = &Nested{Bool: true}
+				return t
+			}),
+			wantChangedKeys: []string{"nested_ptr"},
+		},
+		"anonym_ptr2_nested_ptr_change": {
+			current: testData(nil),
+			patch:   `{"anonym_ptr2_string":"test2","anonym_ptr2_nested_ptr":{"string_ptr":"test3"}}`,
+			want: testData(func(t test) test {
+				t.AnonymPtr2String = "test2"
+				t.AnonymPtr2NestedPtr.StringPtr = stringPtr("test3")
+				return t
+			}),
+			wantChangedKeys: []string{"anonym_ptr2_string", "anonym_ptr2_nested_ptr.string_ptr"},
+		},
+	} {
+		got := data.current
+		gotChangedKeys, err := MergePatchJSON(&got, []byte(data.patch))
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		if diff := pretty.Diff(data.want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, pretty.Diff(data.want, got))
+		}
+
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, pretty.Diff(data.wantChangedKeys, gotChangedKeys))
+		}
+	}
+}
+
+func TestMergePatchJSONArrays(t *testing.T) {
+	type arraysTest struct {
+		Items    []Nested  `json:"items"`
+		ItemPtrs []*Nested `json:"item_ptrs"`
+		Array    [2]Nested `json:"array"`
+		Labels   []string  `json:"labels"`
+	}
+
+	current := arraysTest{
+		Items:    []Nested{{String: "item1", Bool: true}, {String: "item2"}},
+		ItemPtrs: []*Nested{{String: "item1", Bool: true}},
+		Array:    [2]Nested{{String: "item1", Bool: true}, {String: "item2"}},
+		Labels:   []string{"a", "b"},
+	}
+	items, itemPtr := current.Items, current.ItemPtrs[0]
+
+	got := current
+	gotChangedKeys, err := MergePatchJSON(&got, []byte(`{"items":[{"bool":false}],"item_ptrs":[{"string":"item3"}],"array":[{"string":"item3"}],"labels":["c"]}`))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	want := arraysTest{
+		Items:    []Nested{{}},
+		ItemPtrs: []*Nested{{String: "item3"}},
+		Array:    [2]Nested{{String: "item3"}},
+		Labels:   []string{"c"},
+	}
+	if diff := pretty.Diff(want, got); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", diff)
+	}
+	if diff := pretty.Diff([]Nested{{String: "item1", Bool: true}, {String: "item2"}}, items); len(diff) > 0 {
+		t.Errorf("previous items modified: %v", diff)
+	}
+	if diff := pretty.Diff(&Nested{String: "item1", Bool: true}, itemPtr); len(diff) > 0 {
+		t.Errorf("previous item pointer modified: %v", diff)
+	}
+
+	wantChangedKeys := []string{"items", "item_ptrs", "array", "labels"}
+	if diff := pretty.Diff(wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+		t.Errorf("changedKeys: diffs (want/got): %v", diff)
+	}
+}
+
+func TestMergePatchJSONUseTag(t *testing.T) {
+	type nestedWithTags struct {
+		String string `patch:"str"`
+		Int    int    `patch:"int"`
+	}
+	type testWithTags struct {
+		Strings []string        `json:"strings" patch:"strs"`
+		Nested  *nestedWithTags `json:"nested" patch:"sub"`
+	}
+
+	got := testWithTags{
+		Strings: []string{"a", "b"},
+		Nested:  &nestedWithTags{String: "string", Int: 1},
+	}
+	gotChangedKeys, err := MergePatchJSON(&got, []byte(`{"strs":["c"],"sub":{"int":2},"strings":["d"]}`), UseTag("patch"))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	want := testWithTags{
+		Strings: []string{"c"},
+		Nested:  &nestedWithTags{String: "string", Int: 2},
+	}
+	if diff := pretty.Diff(want, got); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", pretty.Diff(want, got))
+	}
+
+	wantChangedKeys := []string{"strs", "sub.int"}
+	if diff := pretty.Diff(wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+		t.Errorf("changedKeys: diffs (want/got): %v", pretty.Diff(wantChangedKeys, gotChangedKeys))
+	}
+}
+
+func TestMergePatchJSONMaps(t *testing.T) {
+	got := entriesTestData(nil)
+	gotChangedKeys, err := MergePatchJSON(&got, []byte(`{"labels":{"env":"dev","team":null},"settings":{"second":{"bool":true}}}`))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	want := entriesTestData(func(t entriesTest) entriesTest {
+		t.Labels = map[string]string{"env": "dev"}
+		t.Settings["second"] = &Nested{Bool: true}
+		return t
+	})
+	if diff := pretty.Diff(want, got); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", pretty.Diff(want, got))
+	}
+
+	wantChangedKeys := []string{"labels.env", "labels.team", "settings.second"}
+	if diff := pretty.Diff(wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+		t.Errorf("changedKeys: diffs (want/got): %v", pretty.Diff(wantChangedKeys, gotChangedKeys))
+	}
+}
+
+func TestMergePatchJSONEqual(t *testing.T) {
+	got := equalTest{Version: &version{Major: 1, Minor: 2}}
+	gotChangedKeys, err := MergePatchJSON(&got, []byte(`{"version":{"major":2}}`))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	want := equalTest{Version: &version{Major: 2, Minor: 2}}
+	if diff := pretty.Diff(want, got); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", pretty.Diff(want, got))
+	}
+
+	wantChangedKeys := []string{"version"}
+	if diff := pretty.Diff(wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+		t.Errorf("changedKeys: diffs (want/got): %v", pretty.Diff(wantChangedKeys, gotChangedKeys))
+	}
+}
+
+func TestMergePatchJSONInvalid(t *testing.T) {
+	for name, patch := range map[string]string{
+		"null":    `null`,
+		"array":   `["string"]`,
+		"invalid": `{"string":`,
+		"type":    `{"bool":"string"}`,
+	} {
+		got := testData(nil)
+		_, err := MergePatchJSON(&got, []byte(patch))
+		if err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+		if diff := pretty.Diff(testData(nil), got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, pretty.Diff(testData(nil), got))
+		}
+	}
+}
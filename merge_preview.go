@@ -0,0 +1,30 @@
+package shallow
+
+import (
+	"reflect"
+)
+
+// MergePreview returns what Merge would produce, without changing dest: the update struct is merged into a deep copy
+// of the dest struct, which is returned with the list of updated keys. The copy shares no pointers, slices or maps
+// with dest, so anonym struct pointers allocated by Merge and nested fields changed with the Deep option are only
+// changed in the copy.
+//
+// Dest and update must be a pointer to a non-nil struct of the same type, and the returned preview is a pointer of
+// the same type. Accepts the same options as Merge, except for WithListener.
+func MergePreview(dest interface{}, update interface{}, keys map[string]interface{}, opts ...Option) (preview interface{}, updatedKeys []string, err error) {
+	err = checkStructPointer(dest)
+	if err != nil {
+		return nil, nil, err
+	}
+	destV := reflect.ValueOf(dest)
+
+	previewV := reflect.New(destV.Elem().Type())
+	previewV.Elem().Set(deepCopy(destV.Elem()))
+
+	updatedKeys, err = Merge(previewV.Interface(), update, keys, withOptions(opts, WithListener(nil))...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return previewV.Interface(), updatedKeys, nil
+}
@@ -0,0 +1,69 @@
+package shallow
+
+import (
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+func TestMergePreview(t *testing.T) {
+	orig := testData(func(t test) test {
+		t.AnonymPtr.AnonymPtr2 = nil
+		return t
+	})
+	update := testData(func(t test) test {
+		t.String = "test2"
+		t.NestedPtr.Bool = false
+		t.AnonymPtr.AnonymPtr2.AnonymPtr2Bool = false
+		return t
+	})
+	keys := map[string]interface{}{
+		"string":           nil,
+		"nested_ptr":       map[string]interface{}{"bool": nil},
+		"anonym_ptr2_bool": nil,
+	}
+
+	want := testData(func(t test) test {
+		t.AnonymPtr.AnonymPtr2 = nil
+		return t
+	})
+	wantChangedKeys, err := Merge(&want, &update, keys, Deep())
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	preview, gotChangedKeys, err := MergePreview(&orig, &update, keys, Deep())
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	if diff := pretty.Diff(&want, preview); len(diff) > 0 {
+		t.Errorf("preview: diffs (want/got): %v", diff)
+	}
+	if diff := pretty.Diff(wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+		t.Errorf("changedKeys: diffs (want/got): %v", diff)
+	}
+
+	wantOrig := testData(func(t test) test {
+		t.AnonymPtr.AnonymPtr2 = nil
+		return t
+	})
+	if diff := pretty.Diff(wantOrig, orig); len(diff) > 0 {
+		t.Errorf("dest modified: %v", diff)
+	}
+}
+
+func TestMergePreviewInvalid(t *testing.T) {
+	orig := testData(nil)
+	update := Nested{}
+
+	_, _, err := MergePreview(&orig, &update, nil)
+	if err == nil {
+		t.Errorf("expected error")
+	}
+	_, _, err = MergePreview(orig, &orig, nil)
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,142 @@
+package shallow
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// StaleError is returned by MergeIfUnchanged if dest changed since expectedBase.
+// Dest is left untouched.
+type StaleError struct {
+	// Keys of the changed fields, in the same format as the keys returned by Merge.
+	Keys []string
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("fields changed since the expected base: %v", strings.Join(e.Keys, ", "))
+}
+
+// MergeIfUnchanged merges the update struct into the dest struct the same way as Merge, but only if every field
+// selected by the keys map (every field if it is nil) still has the same value in dest as in expectedBase, even if
+// the update would not change it. Otherwise a *StaleError is returned, listing the fields that changed. The fields
+// are compared as a whole, ignoring their merge strategies (see StrategyTag).
+//
+// If the struct has a version field (see StrategyTag), it must have the same value in dest as in expectedBase too,
+// and it is incremented if Merge changes any field.
+//
+// Dest, expectedBase and update must be a pointer to a non-nil struct of the same type.
+func MergeIfUnchanged(dest interface{}, expectedBase interface{}, update interface{}, keys map[string]interface{}, opts ...Option) (updatedKeys []string, err error) {
+	err = checkSameType(dest, expectedBase)
+	if err != nil {
+		return nil, err
+	}
+
+	return process(dest, update, keys, true, withOptions(opts, withExpectedBase(reflect.ValueOf(expectedBase).Elem()))...)
+}
+
+func withExpectedBase(baseV reflect.Value) Option {
+	return func(o *options) {
+		o.expectedBase = baseV
+	}
+}
+
+// checkUnchanged checks that the fields selected by keys and the version field have the same value in the destV
+// struct as in the expected base, if there is one.
+func (o *options) checkUnchanged(plan *structPlan, destV reflect.Value, keys map[string]interface{}) error {
+	if !o.expectedBase.IsValid() {
+		return nil
+	}
+
+	var stale []string
+	if plan.version != "" {
+		destVersionV, _ := plan.lookup(destV, plan.version, false)
+		baseVersionV, _ := plan.lookup(o.expectedBase, plan.version, false)
+		if !o.equal(destVersionV, baseVersionV, nil) {
+			stale = append(stale, plan.version)
+		}
+	}
+
+	protect, replaceValues := o.protect, o.replaceValues
+	o.protect, o.replaceValues = false, true
+	changed := make([]change, 0)
+	err := processStructs(plan, destV, o.expectedBase, o, keys, fieldPath{}, &changed, false)
+	o.protect, o.replaceValues = protect, replaceValues
+	if err != nil {
+		return err
+	}
+	for _, c := range changed {
+		if key := c.key(); key != plan.version {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) > 0 {
+		return &StaleError{Keys: stale}
+	}
+
+	return nil
+}
+
+// incrementVersion increments the version field of the destV struct, if there is one, and adds it to the changes.
+func (o *options) incrementVersion(plan *structPlan, destV reflect.Value, changes []change) []change {
+	if plan.version == "" {
+		return changes
+	}
+
+	versionV, _ := plan.lookup(destV, plan.version, true)
+	newV := reflect.New(versionV.Type()).Elem()
+	switch versionV.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		newV.SetUint(versionV.Uint() + 1)
+	default:
+		newV.SetInt(versionV.Int() + 1)
+	}
+
+	changes = append(changes, change{
+		path:     fieldPath{}.child(pathStep{name: plan.version}),
+		oldValue: reflect.ValueOf(versionV.Interface()),
+		newValue: newV,
+	})
+	o.set(versionV, newV)
+
+	return changes
+}
+
+// resolvePath returns the value at the given path of the v struct, and false if there is no such value.
+// Values within nil anonym struct pointers are returned as zero values.
+func (o *options) resolvePath(v reflect.Value, path fieldPath) (reflect.Value, bool) {
+	for _, step := range path.steps {
+		v = reflect.Indirect(v)
+		switch v.Kind() {
+		case reflect.Struct:
+			fieldV, ok := lookupField(v, o.tag, step.name, false)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			v = fieldV
+
+			if step.key != "" {
+				plan, err := getPlan(structType(v.Type().Elem()), o.tag)
+				if err != nil {
+					return reflect.Value{}, false
+				}
+				j := o.indexByKey(plan, step.key, v, reflect.ValueOf(step.id))
+				if j < 0 {
+					return reflect.Value{}, false
+				}
+				v = v.Index(j)
+			}
+
+		case reflect.Map:
+			v = v.MapIndex(reflect.ValueOf(step.name).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return reflect.Value{}, false
+			}
+
+		default:
+			return reflect.Value{}, false
+		}
+	}
+
+	return v, true
+}
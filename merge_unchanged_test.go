@@ -0,0 +1,152 @@
+package shallow
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+type VersionAnonym struct {
+	Version uint `json:"version" shallow:"version"`
+}
+
+type versionedTest struct {
+	*VersionAnonym
+	String string  `json:"string"`
+	Int    int     `json:"int"`
+	Nested *Nested `json:"nested"`
+}
+
+func versionedTestData(modify func(versionedTest) versionedTest) versionedTest {
+	data := versionedTest{
+		VersionAnonym: &VersionAnonym{
+			Version: 1,
+		},
+		String: "string_val",
+		Int:    1,
+		Nested: &Nested{String: "nested_string_val"},
+	}
+
+	if modify != nil {
+		data = modify(data)
+	}
+
+	return data
+}
+
+func TestMergeIfUnchanged(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		current         versionedTest
+		incoming        string
+		want            versionedTest
+		wantChangedKeys []string
+		wantStale       []string
+	}{
+		"unchanged": {
+			current:  versionedTestData(nil),
+			incoming: `{"string":"test2","nested":{"string":"test2"},"version":5}`,
+			want: versionedTestData(func(t versionedTest) versionedTest {
+				t.String = "test2"
+				t.Nested = &Nested{String: "test2"}
+				t.Version = 2
+				return t
+			}),
+			wantChangedKeys: []string{"string", "nested.string", "version"},
+		},
+		"other_field_changed": {
+			current: versionedTestData(func(t versionedTest) versionedTest {
+				t.Int = 2
+				t.Version = 2
+				return t
+			}),
+			incoming:  `{"string":"test2"}`,
+			want:      versionedTestData(nil),
+			wantStale: []string{"version"},
+		},
+		"stale": {
+			current: versionedTestData(func(t versionedTest) versionedTest {
+				t.Nested = &Nested{String: "other"}
+				return t
+			}),
+			incoming:  `{"string":"test2","nested":{"string":"test2"}}`,
+			want:      versionedTestData(nil),
+			wantStale: []string{"nested.string"},
+		},
+		"stale_same_as_update": {
+			current: versionedTestData(func(t versionedTest) versionedTest {
+				t.Int = 2
+				return t
+			}),
+			incoming:  `{"string":"test2","int":2}`,
+			want:      versionedTestData(nil),
+			wantStale: []string{"int"},
+		},
+		"no_changes": {
+			current:         versionedTestData(nil),
+			incoming:        `{"string":"string_val"}`,
+			want:            versionedTestData(nil),
+			wantChangedKeys: []string{},
+		},
+	} {
+		update := versionedTest{}
+		err := json.Unmarshal([]byte(data.incoming), &update)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		var keys map[string]interface{}
+		err = json.Unmarshal([]byte(data.incoming), &keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		base := versionedTestData(nil)
+		got := data.current
+		gotChangedKeys, err := MergeIfUnchanged(&got, &base, &update, keys, Deep())
+		if len(data.wantStale) > 0 {
+			staleErr, ok := err.(*StaleError)
+			if !ok {
+				t.Fatalf("%v: expected *StaleError, got: %v", name, err)
+			}
+			if diff := pretty.Diff(data.wantStale, staleErr.Keys); len(diff) > 0 {
+				t.Errorf("%v stale: diffs (want/got): %v", name, diff)
+			}
+			if diff := pretty.Diff(data.current, got); len(diff) > 0 {
+				t.Errorf("%v: dest modified: %v", name, diff)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+
+		if diff := pretty.Diff(data.want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, diff)
+		}
+	}
+}
+
+func TestVersionInvalid(t *testing.T) {
+	type stringVersion struct {
+		Version string `json:"version" shallow:"version"`
+	}
+	type multipleVersions struct {
+		*VersionAnonym
+		Revision int `json:"revision" shallow:"version"`
+	}
+
+	for name, value := range map[string]interface{}{
+		"string":   &stringVersion{},
+		"multiple": &multipleVersions{},
+	} {
+		_, err := Diff(value, value, nil)
+		if err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package shallow
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/proemergotech/errors/v2"
+)
+
+// structPlan is the compiled list of fields of a struct type, as processed with a given tag.
+type structPlan struct {
+	fields []fieldPlan
+	// version is the tag of the version field (see StrategyTag), including the fields of anonym struct pointers
+	version string
+}
+
+// fieldPlan describes a named field, or an anonym struct pointer field, of a struct type.
+// Fields of anonym struct fields are flattened into the plan of the struct containing them,
+// while fields of anonym struct pointer fields are compiled into a separate plan, as the pointer must be followed.
+type fieldPlan struct {
+	// index of the field within the struct, including the indexes of the anonym struct fields containing it
+	index []int
+	// name of the field (see JSONNames), empty for anonym struct pointer fields
+	name string
+	// embedded is the plan of anonym struct pointer fields, nil otherwise
+	embedded *structPlan
+	// nested is true if the field is a struct or struct pointer, which can be processed recursively if its plan has
+	// any fields
+	nested bool
+	// equal is the Equal method of the field type, nil if it has none
+	equal equalFunc
+	// strategy selected by the strategy tag of the field
+	strategy strategy
+	// key is the tag of the element field identifying the elements, for the key strategy
+	key string
+	// entries is true if the field is a map with string keys without a strategy, which can be processed entry by entry
+	entries bool
+	// typ is the type of the field
+	typ reflect.Type
+}
+
+// fieldTag selects how the fields of struct types are named.
+type fieldTag struct {
+	// name of the struct tag naming the fields
+	name string
+	// jsonNames is true if the fields are named the same way as by encoding/json (see JSONNames)
+	jsonNames bool
+}
+
+type planKey struct {
+	t   reflect.Type
+	tag fieldTag
+}
+
+type planEntry struct {
+	plan *structPlan
+	err  error
+}
+
+var plans sync.Map
+
+// getPlan returns the plan of the t struct type for the given tag, compiling it on first use.
+func getPlan(t reflect.Type, tag fieldTag) (*structPlan, error) {
+	key := planKey{t: t, tag: tag}
+	if entry, ok := plans.Load(key); ok {
+		return entry.(planEntry).plan, entry.(planEntry).err
+	}
+
+	plan, err := compilePlan(t, tag)
+	entry, _ := plans.LoadOrStore(key, planEntry{plan: plan, err: err})
+
+	return entry.(planEntry).plan, entry.(planEntry).err
+}
+
+func compilePlan(t reflect.Type, tag fieldTag) (*structPlan, error) {
+	if tag.jsonNames {
+		return compileJSONPlan(t, tag.name)
+	}
+
+	return compileTaggedPlan(t, tag.name, make(map[reflect.Type]bool))
+}
+
+// compileTaggedPlan compiles the plan of the fields having the given tag. Compiling contains the struct types
+// whose plans are being compiled, to detect anonym struct pointers embedding themselves.
+func compileTaggedPlan(t reflect.Type, tag string, compiling map[reflect.Type]bool) (*structPlan, error) {
+	compiling[t] = true
+	defer delete(compiling, t)
+
+	plan := &structPlan{}
+	err := compileFields(plan, t, tag, nil, compiling)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func compileFields(plan *structPlan, t reflect.Type, tag string, index []int, compiling map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		fieldIndex := append(index[:len(index):len(index)], i)
+		if ft.Anonymous {
+			switch {
+			case ft.Type.Kind() == reflect.Struct:
+				err := compileFields(plan, ft.Type, tag, fieldIndex, compiling)
+				if err != nil {
+					return embeddedError(err, t, ft)
+				}
+
+			case ft.Type.Kind() == reflect.Ptr && ft.Type.Elem().Kind() == reflect.Struct:
+				if compiling[ft.Type.Elem()] {
+					return &TypeError{Err: ErrRecursiveEmbedded, Type: t, Field: ft.Name}
+				}
+				embedded, err := compileTaggedPlan(ft.Type.Elem(), tag, compiling)
+				if err != nil {
+					return embeddedError(err, t, ft)
+				}
+				err = plan.setVersion(embedded.version)
+				if err != nil {
+					return err
+				}
+				plan.fields = append(plan.fields, fieldPlan{
+					index:    fieldIndex,
+					embedded: embedded,
+				})
+
+			default:
+				return &TypeError{Err: ErrUnsupportedEmbedded, Type: t, Field: ft.Name}
+			}
+
+			continue
+		}
+
+		tagVal := ft.Tag.Get(tag)
+		tagVal = strings.SplitN(tagVal, ",", 2)[0]
+		if tagVal == "" {
+			continue
+		}
+
+		f, err := newFieldPlan(ft, fieldIndex, tagVal)
+		if err != nil {
+			return err
+		}
+		if f.strategy == strategyVersion {
+			err = plan.setVersion(f.name)
+			if err != nil {
+				return err
+			}
+		}
+		plan.fields = append(plan.fields, f)
+	}
+
+	return nil
+}
+
+// embeddedError prefixes the field of a *TypeError returned for a field of the ft anonym field of t with the name
+// of ft, so that the error describes the path of the field within t.
+func embeddedError(err error, t reflect.Type, ft reflect.StructField) error {
+	typeErr, ok := err.(*TypeError)
+	if !ok || typeErr.Field == "" {
+		return err
+	}
+
+	return &TypeError{Err: typeErr.Err, Type: t, Field: ft.Name + "." + typeErr.Field}
+}
+
+// newFieldPlan returns the plan of the ft field with the given index and name.
+func newFieldPlan(ft reflect.StructField, index []int, name string) (fieldPlan, error) {
+	s, key, err := parseStrategy(ft)
+	if err != nil {
+		return fieldPlan{}, err
+	}
+
+	equal := equalMethod(ft.Type)
+
+	return fieldPlan{
+		index:    index,
+		name:     name,
+		nested:   (equal == nil || s == strategyDeep) && isStruct(ft.Type),
+		equal:    equal,
+		strategy: s,
+		key:      key,
+		entries:  s == strategyReplace && ft.Type.Kind() == reflect.Map && ft.Type.Key().Kind() == reflect.String,
+		typ:      ft.Type,
+	}, nil
+}
+
+func (p *structPlan) setVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+	if p.version != "" {
+		return errors.Errorf("multiple version fields: %v, %v", p.version, version)
+	}
+	p.version = version
+
+	return nil
+}
+
+// field returns the field of the v struct described by the plan.
+func (f *fieldPlan) field(v reflect.Value) reflect.Value {
+	if len(f.index) == 1 {
+		return v.Field(f.index[0])
+	}
+
+	return v.FieldByIndex(f.index)
+}
+
+// lookupField returns the field of the structV struct tagged with the given key, traversing anonym fields the same
+// way as processStructs. If the field is found within a nil anonym struct pointer, the pointer is allocated if alloc
+// is true, otherwise the zero value of the field is returned.
+func lookupField(structV reflect.Value, tag fieldTag, key string, alloc bool) (reflect.Value, bool) {
+	plan, err := getPlan(structV.Type(), tag)
+	if err != nil {
+		return reflect.Value{}, false
+	}
+
+	return plan.lookup(structV, key, alloc)
+}
+
+// find returns the plan of the field tagged with the given key, including the fields of anonym struct pointers.
+func (p *structPlan) find(key string) *fieldPlan {
+	for i := range p.fields {
+		f := &p.fields[i]
+		if f.embedded != nil {
+			if found := f.embedded.find(key); found != nil {
+				return found
+			}
+		} else if f.name == key {
+			return f
+		}
+	}
+
+	return nil
+}
+
+func (p *structPlan) lookup(v reflect.Value, key string, alloc bool) (reflect.Value, bool) {
+	for i := range p.fields {
+		f := &p.fields[i]
+		if f.embedded == nil {
+			if f.name == key {
+				return f.field(v), true
+			}
+			continue
+		}
+
+		ptrV := f.field(v)
+		if !ptrV.IsNil() {
+			if found, ok := f.embedded.lookup(ptrV.Elem(), key, alloc); ok {
+				return found, true
+			}
+			continue
+		}
+
+		newV := reflect.New(ptrV.Type().Elem())
+		if found, ok := f.embedded.lookup(newV.Elem(), key, alloc); ok {
+			if !alloc {
+				return reflect.Zero(found.Type()), true
+			}
+			if !ptrV.CanSet() {
+				// anonym pointers to unexported structs cannot be allocated
+				return reflect.Value{}, false
+			}
+			ptrV.Set(newV)
+			return found, true
+		}
+	}
+
+	return reflect.Value{}, false
+}
@@ -0,0 +1,198 @@
+package shallow
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+func TestPlan(t *testing.T) {
+	plan, err := getPlan(reflect.TypeOf(AnonymPtr{}), fieldTag{name: "json", jsonNames: true})
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	gotNames := make([]string, 0)
+	var gotIndexes [][]int
+	for _, f := range plan.fields {
+		gotNames = append(gotNames, f.name)
+		gotIndexes = append(gotIndexes, f.index)
+	}
+
+	wantNames := []string{"anonym_ptr_string", "anonym_ptr_string_ptr", "anonym_ptr_bool", "anonym_ptr_bool_ptr", "anonym_ptr_nested", "anonym_ptr_nested_ptr", ""}
+	if diff := pretty.Diff(wantNames, gotNames); len(diff) > 0 {
+		t.Errorf("names: diffs (want/got): %v", diff)
+	}
+	wantIndexes := [][]int{{0}, {1}, {2}, {3}, {4}, {5}, {6}}
+	if diff := pretty.Diff(wantIndexes, gotIndexes); len(diff) > 0 {
+		t.Errorf("indexes: diffs (want/got): %v", diff)
+	}
+	if plan.fields[6].embedded == nil || len(plan.fields[6].embedded.fields) != 6 {
+		t.Errorf("embedded plan not compiled: %# v", pretty.Formatter(plan.fields[6]))
+	}
+
+	again, err := getPlan(reflect.TypeOf(AnonymPtr{}), fieldTag{name: "json", jsonNames: true})
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	if again != plan {
+		t.Errorf("plan was not cached")
+	}
+}
+
+func TestPlanFlattensAnonym(t *testing.T) {
+	type testWithAnonym struct {
+		String string `json:"string"`
+		Anonym
+	}
+
+	plan, err := getPlan(reflect.TypeOf(testWithAnonym{}), fieldTag{name: "json", jsonNames: true})
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	gotIndexes := make(map[string][]int)
+	for _, f := range plan.fields {
+		gotIndexes[f.name] = f.index
+	}
+
+	wantIndexes := map[string][]int{
+		"string":            {0},
+		"anonym_string":     {1, 0},
+		"anonym_string_ptr": {1, 1},
+		"anonym_bool":       {1, 2},
+		"anonym_bool_ptr":   {1, 3},
+		"anonym_nested":     {1, 4},
+		"anonym_nested_ptr": {1, 5},
+	}
+	if diff := pretty.Diff(wantIndexes, gotIndexes); len(diff) > 0 {
+		t.Errorf("indexes: diffs (want/got): %v", diff)
+	}
+}
+
+func TestPlanUnsupportedAnonym(t *testing.T) {
+	type Str string
+	type testWithStr struct {
+		String string `json:"string"`
+		Str
+	}
+
+	first := testWithStr{}
+	second := testWithStr{Str: "str"}
+	_, err := Diff(&first, &second, nil, JSONNames(false))
+	if err == nil {
+		t.Errorf("expected error")
+	}
+	_, err = Merge(&first, &second, nil, JSONNames(false))
+	if err == nil {
+		t.Errorf("expected error")
+	}
+
+	// encoding/json names anonym fields of other types by their type name
+	gotChangedKeys, err := Merge(&first, &second, nil)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	wantChangedKeys := []string{"Str"}
+	if diff := pretty.Diff(wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+		t.Errorf("changedKeys: diffs (want/got): %v", diff)
+	}
+}
+
+func TestPlanConcurrent(t *testing.T) {
+	type testConcurrent struct {
+		String string `json:"string"`
+		*AnonymPtr
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			first := testConcurrent{String: "a", AnonymPtr: &AnonymPtr{}}
+			second := testConcurrent{String: "b", AnonymPtr: &AnonymPtr{AnonymPtrBool: true}}
+			gotChangedKeys, err := Diff(&first, &second, nil)
+			if err != nil {
+				t.Errorf("%+v", errors.WithStack(err))
+				return
+			}
+
+			wantChangedKeys := []string{"string", "anonym_ptr_bool"}
+			if diff := pretty.Diff(wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+				t.Errorf("changedKeys: diffs (want/got): %v", diff)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// BenchmarkPlan compares looking up the cached plan of a struct type to compiling it, which Diff and Merge would
+// have to do on every call without the cache.
+func BenchmarkPlan(b *testing.B) {
+	t := reflect.TypeOf(test{})
+	tag := fieldTag{name: "json", jsonNames: true}
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := getPlan(t, tag)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("compiled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := compilePlan(t, tag)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkDiff(b *testing.B) {
+	first := testData(nil)
+	second := testData(func(t test) test {
+		t.String = "test2"
+		return t
+	})
+	keys := map[string]interface{}{
+		"string":                 nil,
+		"nested":                 nil,
+		"anonym_string":          nil,
+		"anonym_ptr_bool":        nil,
+		"anonym_ptr2_nested_ptr": nil,
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := Diff(&first, &second, keys)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	dest := testData(nil)
+	update := testData(func(t test) test {
+		t.String = "test2"
+		return t
+	})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := Merge(&dest, &update, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
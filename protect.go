@@ -0,0 +1,38 @@
+package shallow
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ProtectedFieldsError is returned by Merge and ApplyJSONPatch with the RejectProtected option, if the update would
+// change readonly, writeonce or version fields (see StrategyTag). Dest is left untouched.
+type ProtectedFieldsError struct {
+	// Keys of the protected fields, in the same format as the keys returned by Merge.
+	Keys []string
+}
+
+func (e *ProtectedFieldsError) Error() string {
+	return fmt.Sprintf("protected fields can't be changed: %v", strings.Join(e.Keys, ", "))
+}
+
+// RejectProtected can be used to make Merge and ApplyJSONPatch return a *ProtectedFieldsError instead of skipping
+// the changes of readonly, writeonce and version fields (see StrategyTag).
+func RejectProtected() Option {
+	return func(o *options) {
+		o.rejectProtected = true
+	}
+}
+
+// protected checks if the targetV field described by f is protected from changes by its strategy.
+func (f *fieldPlan) protected(targetV reflect.Value) bool {
+	switch f.strategy {
+	case strategyReadOnly, strategyVersion:
+		return true
+	case strategyWriteOnce:
+		return !targetV.IsZero()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,245 @@
+package shallow
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+type ProtectedAnonym struct {
+	OwnerID string `json:"owner_id" shallow:"writeonce"`
+}
+
+type protectedInner struct {
+	ID   string `json:"id" shallow:"readonly"`
+	Name string `json:"name"`
+}
+
+type protectedTest struct {
+	ProtectedAnonym
+	ID        string          `json:"id" shallow:"readonly"`
+	CreatedAt time.Time       `json:"created_at" shallow:"readonly"`
+	Name      string          `json:"name"`
+	Inner     *protectedInner `json:"inner"`
+}
+
+func protectedTestData(modify func(protectedTest) protectedTest) protectedTest {
+	data := protectedTest{
+		ProtectedAnonym: ProtectedAnonym{
+			OwnerID: "owner",
+		},
+		ID:        "id",
+		CreatedAt: time.Date(2022, 3, 8, 0, 0, 0, 0, time.UTC),
+		Name:      "name",
+	}
+
+	if modify != nil {
+		data = modify(data)
+	}
+
+	return data
+}
+
+func TestMergeProtected(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		current         protectedTest
+		incoming        string
+		opts            []Option
+		want            protectedTest
+		wantChangedKeys []string
+		wantRejected    []string
+	}{
+		"readonly": {
+			current:  protectedTestData(nil),
+			incoming: `{"id":"other","name":"other"}`,
+			want: protectedTestData(func(t protectedTest) protectedTest {
+				t.Name = "other"
+				return t
+			}),
+			wantChangedKeys: []string{"name"},
+			wantRejected:    []string{"id"},
+		},
+		"readonly_same": {
+			current:         protectedTestData(nil),
+			incoming:        `{"id":"id","created_at":"2022-03-08T01:00:00+01:00"}`,
+			want:            protectedTestData(nil),
+			wantChangedKeys: []string{},
+		},
+		"writeonce": {
+			current:         protectedTestData(nil),
+			incoming:        `{"owner_id":"other"}`,
+			want:            protectedTestData(nil),
+			wantChangedKeys: []string{},
+			wantRejected:    []string{"owner_id"},
+		},
+		"writeonce_zero": {
+			current: protectedTestData(func(t protectedTest) protectedTest {
+				t.OwnerID = ""
+				return t
+			}),
+			incoming: `{"owner_id":"other"}`,
+			want: protectedTestData(func(t protectedTest) protectedTest {
+				t.OwnerID = "other"
+				return t
+			}),
+			wantChangedKeys: []string{"owner_id"},
+		},
+		"readonly_nil_nested": {
+			current:  protectedTestData(nil),
+			incoming: `{"inner":{"id":"other","name":"other"}}`,
+			opts:     []Option{Deep()},
+			want: protectedTestData(func(t protectedTest) protectedTest {
+				t.Inner = &protectedInner{Name: "other"}
+				return t
+			}),
+			wantChangedKeys: []string{"inner"},
+			wantRejected:    []string{"inner.id"},
+		},
+	} {
+		update := protectedTest{}
+		err := json.Unmarshal([]byte(data.incoming), &update)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		var keys map[string]interface{}
+		err = json.Unmarshal([]byte(data.incoming), &keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		got := data.current
+		_, err = Merge(&got, &update, keys, append(data.opts, RejectProtected())...)
+		if len(data.wantRejected) > 0 {
+			protectedErr, ok := err.(*ProtectedFieldsError)
+			if !ok {
+				t.Fatalf("%v: expected *ProtectedFieldsError, got: %v", name, err)
+			}
+			if diff := pretty.Diff(data.wantRejected, protectedErr.Keys); len(diff) > 0 {
+				t.Errorf("%v rejected: diffs (want/got): %v", name, diff)
+			}
+			if diff := pretty.Diff(data.current, got); len(diff) > 0 {
+				t.Errorf("%v: dest modified: %v", name, diff)
+			}
+		} else if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+
+		got = data.current
+		gotChangedKeys, err := Merge(&got, &update, keys, data.opts...)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, diff)
+		}
+	}
+}
+
+func TestDiffProtected(t *testing.T) {
+	first := protectedTestData(nil)
+	second := protectedTestData(func(t protectedTest) protectedTest {
+		t.ID = "other"
+		t.OwnerID = "other"
+		return t
+	})
+
+	gotDiffKeys, err := Diff(&first, &second, nil)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	wantDiffKeys := []string{"owner_id", "id"}
+	if diff := pretty.Diff(wantDiffKeys, gotDiffKeys); len(diff) > 0 {
+		t.Errorf("diffKeys: diffs (want/got): %v", diff)
+	}
+}
+
+func TestApplyJSONPatchProtected(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		current          protectedTest
+		ops              string
+		want             protectedTest
+		wantAffectedKeys []string
+		wantRejected     []string
+	}{
+		"readonly": {
+			current: protectedTestData(nil),
+			ops:     `[{"op":"replace","path":"/id","value":"other"},{"op":"replace","path":"/name","value":"other"}]`,
+			want: protectedTestData(func(t protectedTest) protectedTest {
+				t.Name = "other"
+				return t
+			}),
+			wantAffectedKeys: []string{"name"},
+			wantRejected:     []string{"id"},
+		},
+		"readonly_same": {
+			current:          protectedTestData(nil),
+			ops:              `[{"op":"replace","path":"/id","value":"id"}]`,
+			want:             protectedTestData(nil),
+			wantAffectedKeys: []string{"id"},
+		},
+		"readonly_remove": {
+			current:          protectedTestData(nil),
+			ops:              `[{"op":"remove","path":"/created_at"}]`,
+			want:             protectedTestData(nil),
+			wantAffectedKeys: []string{},
+			wantRejected:     []string{"created_at"},
+		},
+		"writeonce": {
+			current:          protectedTestData(nil),
+			ops:              `[{"op":"copy","from":"/name","path":"/owner_id"}]`,
+			want:             protectedTestData(nil),
+			wantAffectedKeys: []string{},
+			wantRejected:     []string{"owner_id"},
+		},
+		"writeonce_zero": {
+			current: protectedTestData(func(t protectedTest) protectedTest {
+				t.OwnerID = ""
+				return t
+			}),
+			ops: `[{"op":"add","path":"/owner_id","value":"other"}]`,
+			want: protectedTestData(func(t protectedTest) protectedTest {
+				t.OwnerID = "other"
+				return t
+			}),
+			wantAffectedKeys: []string{"owner_id"},
+		},
+	} {
+		got := data.current
+		_, err := ApplyJSONPatch(&got, []byte(data.ops), RejectProtected())
+		if len(data.wantRejected) > 0 {
+			protectedErr, ok := err.(*ProtectedFieldsError)
+			if !ok {
+				t.Fatalf("%v: expected *ProtectedFieldsError, got: %v", name, err)
+			}
+			if diff := pretty.Diff(data.wantRejected, protectedErr.Keys); len(diff) > 0 {
+				t.Errorf("%v rejected: diffs (want/got): %v", name, diff)
+			}
+			if diff := pretty.Diff(data.current, got); len(diff) > 0 {
+				t.Errorf("%v: dest modified: %v", name, diff)
+			}
+		} else if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+
+		got = data.current
+		gotAffectedKeys, err := ApplyJSONPatch(&got, []byte(data.ops))
+		if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(data.wantAffectedKeys, gotAffectedKeys); len(diff) > 0 {
+			t.Errorf("%v affectedKeys: diffs (want/got): %v", name, diff)
+		}
+	}
+}
@@ -1,141 +1,595 @@
 package shallow
 
 import (
+	"context"
+	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
-
-	"github.com/proemergotech/errors/v2"
 )
 
 type options struct {
-	tag string
+	tag             fieldTag
+	jsonNames       *bool
+	deep            bool
+	mergeMaps       bool
+	rejectProtected bool
+	changes         *[]Change
+	comparators     map[reflect.Type]func(a interface{}, b interface{}) bool
+	authorizer      func(ctx context.Context, key string) error
+	ctx             context.Context
+	validation      bool
+	validator       func(dest interface{}) error
+	inverse         *Inverse
+	restoring       bool
+	replaceValues   bool
+	conflictPolicy  ConflictPolicy
+	expectedBase    reflect.Value
+	listener        ChangeListener
+	strict          bool
+	unknownKeys     *[]string
+
+	// protect is true while processing for Merge, in which case the changes of protected fields are skipped
+	protect bool
+	// rejected are the keys of the skipped changes of protected fields
+	rejected []string
+	// undo holds the previous values of the fields set by Merge, if they may have to be rolled back
+	undo []undoEntry
+	// allocated are the paths of the anonym struct pointers allocated by Merge, if the inverse is collected
+	allocated []fieldPath
 }
 
 // Diff compare structs based on the following rule: for every field of the first struct,
-// if the field's tag (specified by tag option, default "json") can be found in the keys map,
-// compare the corresponding value in the first struct to the value in the second struct.
+// if the field's name (see JSONNames), taken from its tag (specified by tag option, default "json"),
+// can be found in the keys map, compare the corresponding value in the first struct to the value in the second struct.
 // If the keys map is nil, all field will be compared.
 //
+// Values are compared with reflect.DeepEqual, or with their Equal method if they have one (see WithComparator).
+// Fields with a merge strategy (see StrategyTag) are compared to the value Merge would set.
+//
 // First and second must be a pointer to a non-nil struct of the same type.
 //
 // Returns with a list of diff keys. This list can include elements that are NOT actually different if the first struct
 // and the second struct had the same value for the given key, and the keys map contained this key.
 //
-// Traverses anonym fields with struct or struct pointer type, even if they are nested (anonym structs
-// within anonym structs). Other types of anonym fields are not supported and will raise an error.
+// Traverses untagged anonym fields with struct or struct pointer type, even if they are nested (anonym structs
+// within anonym structs). Other anonym fields are named by their type name, or raise an error if JSONNames
+// is disabled.
 //
-// Does NOT check nested fields other than anonym.
+// Does NOT check nested fields other than anonym, unless the Deep option is used. In that case nested struct and
+// struct pointer fields are compared field by field, and the keys of differing nested fields are returned as dotted
+// paths, e.g. "nested_ptr.bool_ptr".
 func Diff(first interface{}, second interface{}, keys map[string]interface{}, opts ...Option) (diffKeys []string, err error) {
 	return process(first, second, keys, false, opts...)
 }
 
 // Merge the update struct into the dest struct based on the following rule: for every field of the update struct,
-// if the field's tag (specified by tag option, default "json") can be found in the keys map,
-// set the corresponding value in the dest struct to the value in the update struct.
+// if the field's name (see JSONNames), taken from its tag (specified by tag option, default "json"),
+// can be found in the keys map, set the corresponding value in the dest struct to the value in the update struct.
+// Values that are equal (see WithComparator) are not set. Fields with a merge strategy (see StrategyTag)
+// are set to the value selected by the strategy instead.
 //
 // Dest and update must be a pointer to a non-nil struct of the same type.
 //
 // Returns with a list of updated keys. This list can include elements that are NOT actually changed if the dest struct
 // and the update struct had the same value for the given key, and the keys map contained this key.
 //
-// Traverses anonym fields with struct or struct pointer type, even if they are nested (anonym structs
-// within anonym structs). Other types of anonym fields are not supported and will raise an error.
+// Traverses untagged anonym fields with struct or struct pointer type, even if they are nested (anonym structs
+// within anonym structs). Other anonym fields are named by their type name, or raise an error if JSONNames
+// is disabled.
 //
-// Does NOT merge nested fields other than anonym. For these, either the dest value
+// Does NOT merge nested fields other than anonym, unless the Deep option is used. For these, either the dest value
 // is kept intact, or the update value is used, but the two are never merged.
 func Merge(dest interface{}, update interface{}, keys map[string]interface{}, opts ...Option) (updatedKeys []string, err error) {
 	return process(dest, update, keys, true, opts...)
 }
 
+// MergeContext is the same as Merge, with a context passed to the authorizer (see WithAuthorizer).
+func MergeContext(ctx context.Context, dest interface{}, update interface{}, keys map[string]interface{}, opts ...Option) (updatedKeys []string, err error) {
+	return process(dest, update, keys, true, withOptions(opts, withContext(ctx))...)
+}
+
+// DiffT is the type-safe equivalent of Diff: the compiler guarantees that first and second have the same type.
+// T must be a struct type.
+func DiffT[T any](first *T, second *T, keys map[string]interface{}, opts ...Option) (diffKeys []string, err error) {
+	return process(first, second, keys, false, opts...)
+}
+
+// MergeT is the type-safe equivalent of Merge: the compiler guarantees that dest and update have the same type.
+// T must be a struct type.
+func MergeT[T any](dest *T, update *T, keys map[string]interface{}, opts ...Option) (updatedKeys []string, err error) {
+	return process(dest, update, keys, true, opts...)
+}
+
 func process(target interface{}, source interface{}, keys map[string]interface{}, merge bool, opts ...Option) (processedKeys []string, err error) {
+	changes, err := processChanges(target, source, keys, merge, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return changeKeys(changes), nil
+}
+
+func changeKeys(changes []change) []string {
+	keys := make([]string, 0, len(changes))
+	for _, c := range changes {
+		keys = append(keys, c.key())
+	}
+
+	return keys
+}
+
+// change describes a single processed field, with the values of the target before processing and of the source.
+type change struct {
+	path fieldPath
+	// op is PatchOpAdd or PatchOpRemove for elements added to or removed from a slice, empty otherwise
+	op       string
+	oldValue reflect.Value
+	newValue reflect.Value
+}
+
+func (c change) key() string {
+	return c.path.key()
+}
+
+// fieldPath is the path of a processed field.
+type fieldPath struct {
+	steps []pathStep
+	// index of the anonym struct pointer fields leading from the struct at the path to the processed struct
+	index []int
+}
+
+// pathStep is a field, a map entry (see MergeMaps) or an element of a slice merged by key (see StrategyTag).
+type pathStep struct {
+	// name is the tag of the field, or the key of the map entry
+	name string
+	// key is the tag of the field identifying the element, empty for fields and map entries
+	key string
+	// id is the value of the field identifying the element
+	id interface{}
+	// index of the element in the slice
+	index int
+}
+
+func (s pathStep) String() string {
+	if s.key == "" {
+		return s.name
+	}
+
+	return fmt.Sprintf("%v[%v=%v]", s.name, s.key, s.id)
+}
+
+func (p fieldPath) child(step pathStep) fieldPath {
+	return fieldPath{
+		steps: append(p.steps[:len(p.steps):len(p.steps)], step),
+	}
+}
+
+func (p fieldPath) embed(index []int) fieldPath {
+	return fieldPath{
+		steps: p.steps,
+		index: append(p.index[:len(p.index):len(p.index)], index...),
+	}
+}
+
+// keys returns the path as keys, e.g. "items[id=42]", "qty".
+func (p fieldPath) keys() []string {
+	keys := make([]string, 0, len(p.steps))
+	for _, s := range p.steps {
+		keys = append(keys, s.String())
+	}
+
+	return keys
+}
+
+func (p fieldPath) key() string {
+	return strings.Join(p.keys(), ".")
+}
+
+// tokens returns the path as JSON Pointer reference tokens, e.g. "items", "3", "qty".
+func (p fieldPath) tokens() []string {
+	tokens := make([]string, 0, len(p.steps))
+	for _, s := range p.steps {
+		tokens = append(tokens, s.name)
+		if s.key != "" {
+			tokens = append(tokens, strconv.Itoa(s.index))
+		}
+	}
+
+	return tokens
+}
+
+func processChanges(target interface{}, source interface{}, keys map[string]interface{}, merge bool, opts ...Option) (changes []change, err error) {
+	err = checkSameType(target, source)
+	if err != nil {
+		return nil, err
+	}
 	targetV := reflect.ValueOf(target)
 	sourceV := reflect.ValueOf(source)
-	if targetV.Kind() != reflect.Ptr || targetV.Elem().Kind() != reflect.Struct {
-		return nil, errors.New("target and source must be a non-nil pointer to a struct with the same type")
+
+	o := newOptions(opts)
+	if merge && o.listener != nil {
+		o.listener.BeginMerge(target)
+		defer func() {
+			o.listener.EndMerge(target, err)
+		}()
 	}
-	if targetV.Type() != sourceV.Type() {
-		return nil, errors.New("target and source must be a non-nil pointer to a struct with the same type")
+
+	plan, err := getPlan(targetV.Type().Elem(), o.tag)
+	if err != nil {
+		return nil, err
 	}
-	if targetV.IsNil() || sourceV.IsNil() {
-		return nil, errors.New("target and source must be a non-nil pointer to a struct with the same type")
+
+	if o.strict || o.unknownKeys != nil {
+		unknown, err := o.findUnknownKeys(plan, keys, "", false)
+		if err != nil {
+			return nil, err
+		}
+		if o.unknownKeys != nil {
+			*o.unknownKeys = unknown
+		}
+		if o.strict && len(unknown) > 0 {
+			return nil, &UnknownKeysError{Keys: unknown}
+		}
 	}
 
-	o := &options{
-		tag: "json",
+	if merge && (o.rejectProtected || o.authorizer != nil || o.expectedBase.IsValid()) {
+		// check the changes before applying any of them
+		o.protect = !o.restoring
+		pending := make([]change, 0)
+		err = processStructs(plan, targetV.Elem(), sourceV.Elem(), o, keys, fieldPath{}, &pending, false)
+		if err != nil {
+			return nil, err
+		}
+		if o.rejectProtected && len(o.rejected) > 0 {
+			return nil, &ProtectedFieldsError{Keys: o.rejected}
+		}
+		err = o.authorize(changeKeys(pending))
+		if err != nil {
+			return nil, err
+		}
+		err = o.checkUnchanged(plan, targetV.Elem(), keys)
+		if err != nil {
+			return nil, err
+		}
+		o.rejected = nil
 	}
-	for _, opt := range opts {
-		opt(o)
+
+	var prevV reflect.Value
+	if merge && o.inverse != nil {
+		prevV = deepCopy(targetV.Elem())
 	}
 
-	processedKeys = make([]string, 0)
-	err = processStructs(targetV.Elem(), sourceV.Elem(), o.tag, keys, &processedKeys, merge)
+	o.protect = merge && !o.restoring
+	changes = make([]change, 0)
+	err = processStructs(plan, targetV.Elem(), sourceV.Elem(), o, keys, fieldPath{}, &changes, merge)
 	if err != nil {
 		return nil, err
 	}
 
-	return processedKeys, nil
+	if merge && o.expectedBase.IsValid() && len(changes) > 0 {
+		changes = o.incrementVersion(plan, targetV.Elem(), changes)
+	}
+
+	if merge && o.validation && len(changes) > 0 {
+		err = o.validate(targetV.Interface())
+		if err != nil {
+			o.rollback()
+			return nil, &ValidationError{Keys: changeKeys(changes), Err: err}
+		}
+	}
+
+	if o.changes != nil {
+		*o.changes = exportChanges(changes)
+	}
+	if merge && o.listener != nil {
+		for _, c := range changes {
+			o.listener.OnChange(c.export())
+		}
+	}
+	if prevV.IsValid() {
+		o.collectInverse(prevV, changes)
+	}
+
+	return changes, nil
 }
 
-func processStructs(targetV reflect.Value, sourceV reflect.Value, tag string, keys map[string]interface{}, processedKeys *[]string, merge bool) error {
-	for i := 0; i < sourceV.NumField(); i++ {
-		ft := sourceV.Type().Field(i)
-		if ft.Anonymous {
-			destAVal := targetV.Field(i)
-			upAVal := sourceV.Field(i)
-			if destAVal.Kind() == reflect.Struct {
-				err := processStructs(destAVal, upAVal, tag, keys, processedKeys, merge)
-				if err != nil {
-					return err
-				}
-			} else if destAVal.Kind() == reflect.Ptr && destAVal.Elem().Kind() == reflect.Struct {
-				if upAVal.IsNil() {
-					continue
-				}
+func processStructs(plan *structPlan, targetV reflect.Value, sourceV reflect.Value, o *options, keys map[string]interface{}, path fieldPath, changes *[]change, merge bool) error {
+	for i := range plan.fields {
+		f := &plan.fields[i]
+		if f.embedded != nil {
+			destAVal := f.field(targetV)
+			upAVal := f.field(sourceV)
+			if upAVal.IsNil() {
+				continue
+			}
 
-				if destAVal.IsNil() {
-					destAVal.Set(reflect.New(destAVal.Type().Elem()))
+			if destAVal.IsNil() {
+				if !merge {
+					// compare to the zero value, as a nil target would be allocated by merge
+					destAVal = reflect.New(destAVal.Type().Elem())
+				} else {
+					if !destAVal.CanSet() {
+						name := fieldPathName(targetV.Type(), f.index)
+						return &TypeError{Err: ErrUnexportedEmbedded, Type: targetV.Type(), Field: name}
+					}
+					o.set(destAVal, reflect.New(destAVal.Type().Elem()))
+					if o.inverse != nil {
+						o.allocated = append(o.allocated, path.embed(f.index))
+					}
 				}
+			}
 
-				err := processStructs(destAVal.Elem(), upAVal.Elem(), tag, keys, processedKeys, merge)
-				if err != nil {
-					return err
-				}
-			} else {
-				return errors.New("this method only handles anonym fields of kind struct or pointer to struct")
+			err := processStructs(f.embedded, destAVal.Elem(), upAVal.Elem(), o, keys, path.embed(f.index), changes, merge)
+			if err != nil {
+				return err
 			}
 
 			continue
 		}
 
-		tagVal := ft.Tag.Get(tag)
-		tagVal = strings.SplitN(tagVal, ",", 2)[0]
-		if tagVal == "" {
+		var keyVal interface{}
+		var nestedKeys map[string]interface{}
+		if keys != nil {
+			var ok bool
+			keyVal, ok = keys[f.name]
+			if !ok {
+				continue
+			}
+			nestedKeys, _ = keyVal.(map[string]interface{})
+		}
+
+		targetFieldV := f.field(targetV)
+		sourceFieldV := f.field(sourceV)
+		if o.protect && f.protected(targetFieldV) {
+			if !o.equal(targetFieldV, sourceFieldV, f.equal) {
+				o.rejected = append(o.rejected, path.child(pathStep{name: f.name}).key())
+			}
+
 			continue
 		}
-		if keys != nil {
-			if _, ok := keys[tagVal]; !ok {
+
+		if f.strategy == strategyKey {
+			err := o.processKeyed(f, targetFieldV, sourceFieldV, keyVal, path, changes, merge)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if o.mergeMaps && f.entries && (keys == nil || nestedKeys != nil) && o.comparator(targetFieldV.Type()) == nil {
+			o.processMap(targetFieldV, sourceFieldV, nestedKeys, path.child(pathStep{name: f.name}), changes, merge)
+			continue
+		}
+
+		deep := f.strategy == strategyDeep || (o.deep && o.comparator(targetFieldV.Type()) == nil)
+		if deep && f.nested && (keys == nil || nestedKeys != nil) {
+			handled, err := processNested(targetFieldV, sourceFieldV, o, nestedKeys, path.child(pathStep{name: f.name}), changes, merge)
+			if err != nil {
+				return err
+			}
+			if handled {
 				continue
 			}
 		}
 
-		if reflect.DeepEqual(targetV.Field(i).Interface(), sourceV.Field(i).Interface()) {
+		mergedV := o.mergedValue(f, targetFieldV, sourceFieldV)
+		if o.equal(targetFieldV, mergedV, f.equal) {
 			continue
 		}
 
-		*processedKeys = append(*processedKeys, tagVal)
+		*changes = append(*changes, change{
+			path:     path.child(pathStep{name: f.name}),
+			oldValue: reflect.ValueOf(targetFieldV.Interface()),
+			newValue: mergedV,
+		})
 		if merge {
-			targetV.Field(i).Set(sourceV.Field(i))
+			o.set(targetFieldV, mergedV)
 		}
 	}
 
 	return nil
 }
 
+// fieldPathName returns the Go field names leading to the field of t with the given index, separated by dots.
+func fieldPathName(t reflect.Type, index []int) string {
+	names := make([]string, 0, len(index))
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		names = append(names, t.Field(i).Name)
+		t = t.Field(i).Type
+	}
+
+	return strings.Join(names, ".")
+}
+
+// processNested recurses into a nested struct or struct pointer field, processing only the given keys.
+// Returns false if the field must be processed as a whole instead, e.g. because its type has no named fields
+// (like big.Int).
+func processNested(targetV reflect.Value, sourceV reflect.Value, o *options, keys map[string]interface{}, path fieldPath, changes *[]change, merge bool) (bool, error) {
+	if targetV.Kind() == reflect.Ptr {
+		if sourceV.IsNil() {
+			return false, nil
+		}
+
+		plan, err := getPlan(targetV.Type().Elem(), o.tag)
+		if err != nil {
+			return true, err
+		}
+		if len(plan.fields) == 0 {
+			return false, nil
+		}
+
+		if !targetV.IsNil() {
+			return true, processStructs(plan, targetV.Elem(), sourceV.Elem(), o, keys, path, changes, merge)
+		}
+
+		// a nil target is reported as a whole, as it changes to non-nil even if none of its fields do
+		c := change{
+			path:     path,
+			oldValue: reflect.ValueOf(targetV.Interface()),
+			newValue: sourceV,
+		}
+		if merge || o.protect {
+			// without merging, the fields are still processed against the zero value to check the protected fields
+			newV := reflect.New(targetV.Type().Elem())
+			err := processStructs(plan, newV.Elem(), sourceV.Elem(), o, keys, path, &[]change{}, merge)
+			if err != nil {
+				return true, err
+			}
+			if merge {
+				o.set(targetV, newV)
+				c.newValue = newV
+			}
+		}
+		*changes = append(*changes, c)
+
+		return true, nil
+	}
+
+	plan, err := getPlan(targetV.Type(), o.tag)
+	if err != nil {
+		return true, err
+	}
+	if len(plan.fields) == 0 {
+		return false, nil
+	}
+
+	return true, processStructs(plan, targetV, sourceV, o, keys, path, changes, merge)
+}
+
+// processMap processes the entries of the sourceV map field into the targetV map field one by one (see MergeMaps).
+// If keys is nil, all entries of both maps are processed.
+func (o *options) processMap(targetV reflect.Value, sourceV reflect.Value, keys map[string]interface{}, path fieldPath, changes *[]change, merge bool) {
+	mapType := targetV.Type()
+	names := make([]string, 0, len(keys))
+	if keys != nil {
+		for name := range keys {
+			names = append(names, name)
+		}
+	} else {
+		seen := make(map[string]bool, targetV.Len()+sourceV.Len())
+		for _, m := range []reflect.Value{targetV, sourceV} {
+			iter := m.MapRange()
+			for iter.Next() {
+				name := iter.Key().String()
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+
+	eq := equalMethod(mapType.Elem())
+	changeCount := len(*changes)
+	resultV := reflect.MakeMapWithSize(mapType, targetV.Len())
+	iter := targetV.MapRange()
+	for iter.Next() {
+		resultV.SetMapIndex(iter.Key(), iter.Value())
+	}
+	for _, name := range names {
+		keyV := reflect.ValueOf(name).Convert(mapType.Key())
+		c := change{
+			path:     path.child(pathStep{name: name}),
+			oldValue: targetV.MapIndex(keyV),
+			newValue: sourceV.MapIndex(keyV),
+		}
+
+		switch {
+		case !c.newValue.IsValid() || isNil(c.newValue) || (keys != nil && keys[name] == nil):
+			if !c.oldValue.IsValid() {
+				continue
+			}
+			c.op = PatchOpRemove
+			c.newValue = reflect.Zero(mapType.Elem())
+			resultV.SetMapIndex(keyV, reflect.Value{})
+
+		case !c.oldValue.IsValid():
+			c.op = PatchOpAdd
+			c.oldValue = reflect.Zero(mapType.Elem())
+			resultV.SetMapIndex(keyV, c.newValue)
+
+		default:
+			if o.equal(c.oldValue, c.newValue, eq) {
+				continue
+			}
+			resultV.SetMapIndex(keyV, c.newValue)
+		}
+
+		*changes = append(*changes, c)
+	}
+
+	if merge && len(*changes) > changeCount {
+		o.set(targetV, resultV)
+	}
+}
+
 type Option func(*options)
 
-// UseTag can be used to use struct tags other than json.
+// withOptions returns opts followed by the given options, without writing into the backing array of opts, which may
+// be shared by the caller.
+func withOptions(opts []Option, with ...Option) []Option {
+	return append(opts[:len(opts):len(opts)], with...)
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		tag: fieldTag{name: "json"},
+		ctx: context.Background(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.tag.jsonNames = o.tag.name == "json"
+	if o.jsonNames != nil {
+		o.tag.jsonNames = *o.jsonNames
+	}
+
+	return o
+}
+
+// UseTag can be used to use struct tags other than json. Fields are named the same way as by encoding/json only
+// if the JSONNames option is used as well.
 func UseTag(tag string) Option {
 	return func(o *options) {
-		o.tag = tag
+		o.tag.name = tag
+	}
+}
+
+// Deep can be used to process nested struct and struct pointer fields recursively. A field is recursed into
+// if the keys map is nil, or if its value in the keys map is itself a map[string]interface{} (as produced by
+// json.Unmarshal), in which case only the nested keys listed there are processed.
+// Keys within nested fields are returned as dotted paths, e.g. "nested.string". Struct pointers changing
+// from or to nil are returned as a whole.
+func Deep() Option {
+	return func(o *options) {
+		o.deep = true
+	}
+}
+
+// MergeMaps can be used to process map fields with string keys entry by entry, instead of as a whole.
+// If the keys map is nil, all entries are processed, and entries missing from the update are deleted.
+// If the value of the field in the keys map is a map[string]interface{} (as produced by json.Unmarshal), only
+// the entries listed there are processed, and entries with a nil value in the keys map (JSON null) are deleted.
+// Entries with a nil value in the update are deleted in both cases.
+// Keys of the entries are returned as dotted paths, e.g. "labels.env". Map fields with a merge strategy
+// (see StrategyTag) or with a comparator (see WithComparator) are processed as a whole.
+func MergeMaps() Option {
+	return func(o *options) {
+		o.mergeMaps = true
+	}
+}
+
+// CollectChanges can be used to get the old and new values of the processed fields, e.g. to log what was
+// overwritten by Merge. The changes are stored in the given slice, in the same order as the returned keys.
+func CollectChanges(changes *[]Change) Option {
+	return func(o *options) {
+		o.changes = changes
 	}
 }
@@ -262,3 +262,153 @@ func TestDiff(t *testing.T) {
 		}
 	}
 }
+
+func TestDiffDeep(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		first           test
+		second          test
+		keys            map[string]interface{}
+		wantChangedKeys []string
+	}{
+		"same": {
+			first:           testData(nil),
+			second:          testData(nil),
+			wantChangedKeys: []string{},
+		},
+		"nested_ptr_bool_ptr_change": {
+			first: testData(nil),
+			second: testData(func(t test) test {
+				t.NestedPtr = &Nested{
+					String:    "nested_ptr_string_val",
+					StringPtr: stringPtr("nested_ptr_string_ptr_val"),
+					Bool:      true,
+					BoolPtr:   boolPtr(false),
+				}
+				return t
+			}),
+			wantChangedKeys: []string{"nested_ptr.bool_ptr"},
+		},
+		"nested_ptr_null": {
+			first: testData(nil),
+			second: testData(func(t test) test {
+				t.NestedPtr = nil
+				return t
+			}),
+			wantChangedKeys: []string{"nested_ptr"},
+		},
+		"nested_ptr_from_null": {
+			first: testData(func(t test) test {
+				t.NestedPtr = nil
+				return t
+			}),
+			second:          testData(nil),
+			wantChangedKeys: []string{"nested_ptr"},
+		},
+		"multiple_change": {
+			first: testData(nil),
+			second: testData(func(t test) test {
+				t.String = "test2"
+				t.Nested.Bool = false
+				t.AnonymPtr = &AnonymPtr{
+					AnonymPtrString: "anonym_ptr_string_val",
+					AnonymPtrNested: Nested{String: "test3"},
+				}
+				return t
+			}),
+			wantChangedKeys: []string{
+				"string",
+				"nested.bool",
+				"anonym_ptr_string_ptr",
+				"anonym_ptr_bool",
+				"anonym_ptr_bool_ptr",
+				"anonym_ptr_nested.string",
+				"anonym_ptr_nested.string_ptr",
+				"anonym_ptr_nested.bool",
+				"anonym_ptr_nested.bool_ptr",
+				"anonym_ptr_nested_ptr",
+			},
+		},
+		"keys": {
+			first: testData(nil),
+			second: testData(func(t test) test {
+				t.String = "test2"
+				t.Nested = Nested{}
+				return t
+			}),
+			keys: map[string]interface{}{
+				"nested": map[string]interface{}{
+					"string": nil,
+					"bool":   nil,
+				},
+			},
+			wantChangedKeys: []string{"nested.string", "nested.bool"},
+		},
+	} {
+		gotChangedKeys, err := Diff(&data.first, &data.second, data.keys, Deep())
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, pretty.Diff(data.wantChangedKeys, gotChangedKeys))
+		}
+	}
+}
+
+func TestDiffMaps(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		first           entriesTest
+		second          entriesTest
+		wantChangedKeys []string
+	}{
+		"same": {
+			first:           entriesTestData(nil),
+			second:          entriesTestData(nil),
+			wantChangedKeys: []string{},
+		},
+		"entries_changed": {
+			first: entriesTestData(nil),
+			second: entriesTestData(func(t entriesTest) entriesTest {
+				t.Labels = map[string]string{"env": "dev", "app": "shallow"}
+				t.Settings = map[string]*Nested{"second": {}}
+				return t
+			}),
+			wantChangedKeys: []string{"labels.app", "labels.env", "labels.team", "settings.first", "settings.second"},
+		},
+		"from_nil": {
+			first: entriesTestData(func(t entriesTest) entriesTest {
+				t.Labels = nil
+				return t
+			}),
+			second:          entriesTestData(nil),
+			wantChangedKeys: []string{"labels.env", "labels.team"},
+		},
+	} {
+		first := data.first
+		gotChangedKeys, err := Diff(&first, &data.second, nil, MergeMaps())
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, diff)
+		}
+
+		patch, err := DiffPatch(&first, &data.second, nil, MergeMaps())
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		patchJSON, err := json.Marshal(patch)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		_, err = ApplyJSONPatch(&first, patchJSON)
+		if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.second, first); len(diff) > 0 {
+			t.Errorf("%v patched: diffs (want/got): %v", name, diff)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package shallow
 
 import (
 	"encoding/json"
+	"math/big"
 	"testing"
 
 	"github.com/kr/pretty"
@@ -403,3 +404,280 @@ func TestMerge(t *testing.T) {
 		}
 	}
 }
+
+func TestMergeDeep(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		current         test
+		incoming        string
+		want            test
+		wantChangedKeys []string
+	}{
+		"nested_string_change": {
+			current:  testData(nil),
+			incoming: `{"nested":{"string":"test2"}}`,
+			want: testData(func(t test) test {
+				t.Nested.String = "test2"
+				return t
+			}),
+			wantChangedKeys: []string{"nested.string"},
+		},
+		"nested_string_ptr_null": {
+			current:  testData(nil),
+			incoming: `{"nested":{"string_ptr":null}}`,
+			want: testData(func(t test) test {
+				t.Nested.StringPtr = nil
+				return t
+			}),
+			wantChangedKeys: []string{"nested.string_ptr"},
+		},
+		"nested_same": {
+			current:         testData(nil),
+			incoming:        `{"nested":{"string":"nested_string_val"}}`,
+			want:            testData(nil),
+			wantChangedKeys: []string{},
+		},
+		"nested_null": {
+			current:  testData(nil),
+			incoming: `{"nested":null}`,
+			want: testData(func(t test) test {
+				t.Nested = Nested{}
+				return t
+			}),
+			wantChangedKeys: []string{"nested"},
+		},
+		"nested_ptr_bool_change": {
+			current:  testData(nil),
+			incoming: `{"nested_ptr":{"bool":false}}`,
+			want: testData(func(t test) test {
+				t.NestedPtr.Bool = false
+				return t
+			}),
+			wantChangedKeys: []string{"nested_ptr.bool"},
+		},
+		"nested_ptr_null": {
+			current:  testData(nil),
+			incoming: `{"nested_ptr":null}`,
+			want: testData(func(t test) test {
+				t.NestedPtr = nil
+				return t
+			}),
+			wantChangedKeys: []string{"nested_ptr"},
+		},
+		"nested_ptr_nil_dest": {
+			current: testData(func(t test) test {
+				t.NestedPtr = nil
+				return t
+			}),
+			incoming: `{"nested_ptr":{"string":"test2"}}`,
+			want: testData(func(t test) test {
+				t.NestedPtr = &Nested{String: "test2"}
+				return t
+			}),
+			wantChangedKeys: []string{"nested_ptr"},
+		},
+		"nested_ptr_nil_dest_empty": {
+			current: testData(func(t test) test {
+				t.NestedPtr = nil
+				return t
+			}),
+			incoming: `{"nested_ptr":{}}`,
+			want: testData(func(t test) test {
+				t.NestedPtr = &Nested{}
+				return t
+			}),
+			wantChangedKeys: []string{"nested_ptr"},
+		},
+		"anonym_ptr2_nested_ptr_bool_ptr_change": {
+			current:  testData(nil),
+			incoming: `{"anonym_ptr2_nested_ptr":{"bool_ptr":false}}`,
+			want: testData(func(t test) test {
+				t.AnonymPtr2NestedPtr.BoolPtr = boolPtr(false)
+				return t
+			}),
+			wantChangedKeys: []string{"anonym_ptr2_nested_ptr.bool_ptr"},
+		},
+		"mixed_change": {
+			current:  testData(nil),
+			incoming: `{"string":"test2","nested":{"bool":false},"nested_ptr":{"string":"test3","bool":true}}`,
+			want: testData(func(t test) test {
+				t.String = "test2"
+				t.Nested.Bool = false
+				t.NestedPtr.String = "test3"
+				return t
+			}),
+			wantChangedKeys: []string{"string", "nested.bool", "nested_ptr.string"},
+		},
+	} {
+		orig := data.current
+		update := test{}
+		err := json.Unmarshal([]byte(data.incoming), &update)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		var keys map[string]interface{}
+		err = json.Unmarshal([]byte(data.incoming), &keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		gotChangedKeys, err := Merge(&orig, &update, keys, Deep())
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		got := orig
+
+		if diff := pretty.Diff(data.want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, pretty.Diff(data.want, got))
+		}
+
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, pretty.Diff(data.wantChangedKeys, gotChangedKeys))
+		}
+	}
+}
+
+func TestMergeDeepOpaque(t *testing.T) {
+	type opaque struct {
+		value int
+	}
+	type testWithOpaque struct {
+		Amount *big.Int `json:"amount"`
+		Opaque opaque   `json:"opaque"`
+	}
+
+	orig := testWithOpaque{Amount: big.NewInt(1), Opaque: opaque{value: 1}}
+	update := testWithOpaque{Amount: big.NewInt(2), Opaque: opaque{value: 2}}
+
+	wantChangedKeys := []string{"amount", "opaque"}
+	gotDiffKeys, err := Diff(&orig, &update, nil, Deep())
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	if diff := pretty.Diff(wantChangedKeys, gotDiffKeys); len(diff) > 0 {
+		t.Errorf("diffKeys: diffs (want/got): %v", diff)
+	}
+
+	gotChangedKeys, err := Merge(&orig, &update, nil, Deep())
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	if diff := pretty.Diff(wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+		t.Errorf("changedKeys: diffs (want/got): %v", diff)
+	}
+	if orig.Amount.Int64() != 2 || orig.Opaque.value != 2 {
+		t.Errorf("fields were not merged: %v, %v", orig.Amount, orig.Opaque.value)
+	}
+}
+
+func TestMergeNilAnonymPtr(t *testing.T) {
+	orig := testData(func(t test) test {
+		t.AnonymPtr = nil
+		return t
+	})
+	update := test{
+		AnonymPtr: &AnonymPtr{
+			AnonymPtrString: "test2",
+			AnonymPtr2: &AnonymPtr2{
+				AnonymPtr2Bool: true,
+			},
+		},
+	}
+	keys := map[string]interface{}{
+		"anonym_ptr_string": nil,
+		"anonym_ptr2_bool":  nil,
+	}
+
+	gotChangedKeys, err := Merge(&orig, &update, keys)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	want := testData(func(t test) test {
+		t.AnonymPtr = &AnonymPtr{
+			AnonymPtrString: "test2",
+			AnonymPtr2: &AnonymPtr2{
+				AnonymPtr2Bool: true,
+			},
+		}
+		return t
+	})
+	if diff := pretty.Diff(want, orig); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", diff)
+	}
+
+	wantChangedKeys := []string{"anonym_ptr_string", "anonym_ptr2_bool"}
+	if diff := pretty.Diff(wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+		t.Errorf("changedKeys: diffs (want/got): %v", diff)
+	}
+}
+
+func TestMergeMaps(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		incoming        string
+		want            entriesTest
+		wantChangedKeys []string
+	}{
+		"entry_change": {
+			incoming: `{"labels":{"env":"dev","app":"shallow"}}`,
+			want: entriesTestData(func(t entriesTest) entriesTest {
+				t.Labels = map[string]string{"env": "dev", "team": "core", "app": "shallow"}
+				return t
+			}),
+			wantChangedKeys: []string{"labels.app", "labels.env"},
+		},
+		"entry_delete": {
+			incoming: `{"labels":{"team":null,"unknown":null},"settings":{"first":null}}`,
+			want: entriesTestData(func(t entriesTest) entriesTest {
+				t.Labels = map[string]string{"env": "prod"}
+				t.Settings = map[string]*Nested{}
+				return t
+			}),
+			wantChangedKeys: []string{"labels.team", "settings.first"},
+		},
+		"entry_same": {
+			incoming:        `{"labels":{"env":"prod"},"settings":{"first":{"string":"first_val"}}}`,
+			want:            entriesTestData(nil),
+			wantChangedKeys: []string{},
+		},
+		"map_null": {
+			incoming: `{"labels":null}`,
+			want: entriesTestData(func(t entriesTest) entriesTest {
+				t.Labels = nil
+				return t
+			}),
+			wantChangedKeys: []string{"labels"},
+		},
+		"strategy": {
+			incoming: `{"tags":{"b":["b"]}}`,
+			want: entriesTestData(func(t entriesTest) entriesTest {
+				t.Tags = map[string][]string{"a": {"a"}, "b": {"b"}}
+				return t
+			}),
+			wantChangedKeys: []string{"tags"},
+		},
+	} {
+		update := entriesTest{}
+		err := json.Unmarshal([]byte(data.incoming), &update)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		var keys map[string]interface{}
+		err = json.Unmarshal([]byte(data.incoming), &keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		orig := entriesTestData(nil)
+		gotChangedKeys, err := Merge(&orig, &update, keys, MergeMaps())
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.want, orig); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, diff)
+		}
+	}
+}
@@ -241,3 +241,90 @@ func TestUseTag(t *testing.T) {
 		}
 	}
 }
+
+func TestGeneric(t *testing.T) {
+	orig := testData(nil)
+	update := testData(func(t test) test {
+		t.String = "test2"
+		t.NestedPtr.Bool = false
+		return t
+	})
+	keys := map[string]interface{}{
+		"string":     nil,
+		"nested_ptr": map[string]interface{}{"bool": nil},
+	}
+
+	gotDiffKeys, err := DiffT(&orig, &update, keys, Deep())
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	wantKeys := []string{"string", "nested_ptr.bool"}
+	if diff := pretty.Diff(wantKeys, gotDiffKeys); len(diff) > 0 {
+		t.Errorf("diffKeys: diffs (want/got): %v", diff)
+	}
+
+	gotChangedKeys, err := MergeT(&orig, &update, keys, Deep())
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+	if diff := pretty.Diff(wantKeys, gotChangedKeys); len(diff) > 0 {
+		t.Errorf("changedKeys: diffs (want/got): %v", diff)
+	}
+	if diff := pretty.Diff(update, orig); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", diff)
+	}
+
+	var nilTest *test
+	_, err = MergeT(&orig, nilTest, keys)
+	if err == nil {
+		t.Errorf("expected error for nil pointer")
+	}
+
+	str := "string"
+	_, err = DiffT(&str, &str, nil)
+	if err == nil {
+		t.Errorf("expected error for non-struct type")
+	}
+}
+
+type entriesTest struct {
+	Labels   map[string]string   `json:"labels"`
+	Settings map[string]*Nested  `json:"settings"`
+	Tags     map[string][]string `json:"tags" shallow:"union"`
+}
+
+func entriesTestData(modify func(entriesTest) entriesTest) entriesTest {
+	data := entriesTest{
+		Labels: map[string]string{
+			"env":  "prod",
+			"team": "core",
+		},
+		Settings: map[string]*Nested{
+			"first": {String: "first_val"},
+		},
+		Tags: map[string][]string{
+			"a": {"a"},
+		},
+	}
+
+	if modify != nil {
+		data = modify(data)
+	}
+
+	return data
+}
+
+func TestOptionsNotModified(t *testing.T) {
+	opts := make([]Option, 1, 2)
+	opts[0] = UseTag("json")
+
+	got := testData(nil)
+	_, err := MergePatchJSON(&got, []byte(`{"string":"test2"}`), opts...)
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	if opts[:2][1] != nil {
+		t.Errorf("backing array of the options was modified")
+	}
+}
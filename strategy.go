@@ -0,0 +1,297 @@
+package shallow
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/proemergotech/errors/v2"
+)
+
+// StrategyTag is the struct tag used to select the merge strategy of a field, e.g. `shallow:"append"`.
+//
+// The following strategies are supported:
+//   - append: slices of the update are appended to the slice in dest,
+//   - union: elements of slices in the update are appended to the slice in dest if they are not contained in it yet,
+//     entries of maps in the update are added to the map in dest, overwriting the entries with the same key,
+//   - keep: the value in dest is kept, unless it is the zero value,
+//   - deep: nested struct and struct pointer fields are merged recursively, as with the Deep option,
+//   - key=<name>: elements of slices of structs or struct pointers are merged by their field tagged with name, e.g.
+//     `shallow:"key=id"`. Elements of the update are merged into the element of dest with the same key, with the
+//     keys map of the element (if any) used the same way as the keys map of Merge. Elements with a new key are
+//     appended, and elements whose keys map contains DeleteMarker set to true are removed.
+//     Keys of the elements are returned with their key, e.g. "items[id=42].qty",
+//   - readonly: the value in dest is never changed by Merge,
+//   - writeonce: the value in dest is only changed by Merge if it is the zero value,
+//   - version: integer field which is never changed by Merge, but is checked and incremented by MergeIfUnchanged.
+//     A struct can have only one version field.
+//
+// Merge skips the changes of readonly, writeonce and version fields, or returns a *ProtectedFieldsError if the
+// RejectProtected option is used. These fields are compared by Diff as if they had no strategy.
+//
+// Without a strategy, the value in dest is replaced by the value in the update.
+// Strategies are applied by Diff too, which reports the fields that Merge would change.
+const StrategyTag = "shallow"
+
+// DeleteMarker removes an element from a slice merged by key (see StrategyTag), if it is set to true in the keys map
+// of the element, e.g. {"items":[{"id":42,"$delete":true}]}.
+const DeleteMarker = "$delete"
+
+type strategy int
+
+const (
+	strategyReplace strategy = iota
+	strategyAppend
+	strategyUnion
+	strategyKeep
+	strategyDeep
+	strategyKey
+	strategyReadOnly
+	strategyWriteOnce
+	strategyVersion
+)
+
+// parseStrategy parses the strategy tag of a struct field. The key is only returned for the key strategy.
+func parseStrategy(ft reflect.StructField) (s strategy, key string, err error) {
+	tagVal, ok := ft.Tag.Lookup(StrategyTag)
+	if !ok {
+		return strategyReplace, "", nil
+	}
+
+	s = strategyReplace
+	for _, opt := range strings.Split(tagVal, ",") {
+		var optStrategy strategy
+		var valid bool
+		switch {
+		case opt == "append":
+			optStrategy = strategyAppend
+			valid = ft.Type.Kind() == reflect.Slice
+		case opt == "union":
+			optStrategy = strategyUnion
+			valid = ft.Type.Kind() == reflect.Slice || ft.Type.Kind() == reflect.Map
+		case opt == "keep":
+			optStrategy = strategyKeep
+			valid = true
+		case opt == "deep":
+			optStrategy = strategyDeep
+			valid = isStruct(ft.Type)
+		case opt == "readonly":
+			optStrategy = strategyReadOnly
+			valid = true
+		case opt == "writeonce":
+			optStrategy = strategyWriteOnce
+			valid = true
+		case opt == "version":
+			optStrategy = strategyVersion
+			valid = isInt(ft.Type)
+		case strings.HasPrefix(opt, "key="):
+			optStrategy = strategyKey
+			key = strings.TrimPrefix(opt, "key=")
+			valid = ft.Type.Kind() == reflect.Slice && isStruct(ft.Type.Elem()) && key != ""
+		default:
+			return 0, "", errors.Errorf("field %v: unknown %v tag option: %q", ft.Name, StrategyTag, opt)
+		}
+
+		if !valid {
+			return 0, "", errors.Errorf("field %v: %v tag option %q is not supported for type %v", ft.Name, StrategyTag, opt, ft.Type)
+		}
+		if s != strategyReplace {
+			return 0, "", errors.Errorf("field %v: multiple strategies in %v tag", ft.Name, StrategyTag)
+		}
+		s = optStrategy
+	}
+
+	return s, key, nil
+}
+
+// isStruct checks if t is a struct or a struct pointer.
+func isStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct || (t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct)
+}
+
+// isInt checks if t is a signed or unsigned integer type.
+func isInt(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// structType returns the element type of t if it is a pointer, t otherwise.
+func structType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+
+	return t
+}
+
+// mergedValue returns the value that the field described by f should have after merging the source value into the
+// target value. The returned value never shares its backing array or map with the target value.
+func (o *options) mergedValue(f *fieldPlan, targetV reflect.Value, sourceV reflect.Value) reflect.Value {
+	if o.restoring || o.replaceValues {
+		return sourceV
+	}
+
+	switch f.strategy {
+	case strategyAppend:
+		if sourceV.Len() == 0 {
+			return targetV
+		}
+
+		return concatSlices(targetV, sourceV)
+
+	case strategyUnion:
+		if targetV.Kind() == reflect.Map {
+			if sourceV.Len() == 0 {
+				return targetV
+			}
+
+			newV := reflect.MakeMapWithSize(targetV.Type(), targetV.Len()+sourceV.Len())
+			for _, m := range []reflect.Value{targetV, sourceV} {
+				iter := m.MapRange()
+				for iter.Next() {
+					newV.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+
+			return newV
+		}
+
+		eq := equalMethod(targetV.Type().Elem())
+		missingV := reflect.MakeSlice(sourceV.Type(), 0, sourceV.Len())
+		for i := 0; i < sourceV.Len(); i++ {
+			if !o.containsValue(targetV, sourceV.Index(i), eq) && !o.containsValue(missingV, sourceV.Index(i), eq) {
+				missingV = reflect.Append(missingV, sourceV.Index(i))
+			}
+		}
+		if missingV.Len() == 0 {
+			return targetV
+		}
+
+		return concatSlices(targetV, missingV)
+
+	case strategyKeep:
+		if !targetV.IsZero() {
+			return targetV
+		}
+
+		return sourceV
+
+	default:
+		return sourceV
+	}
+}
+
+func (o *options) containsValue(sliceV reflect.Value, v reflect.Value, eq equalFunc) bool {
+	for i := 0; i < sliceV.Len(); i++ {
+		if o.equal(sliceV.Index(i), v, eq) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// concatSlices returns a new slice with the elements of a followed by the elements of b.
+func concatSlices(a reflect.Value, b reflect.Value) reflect.Value {
+	newV := reflect.MakeSlice(a.Type(), a.Len()+b.Len(), a.Len()+b.Len())
+	reflect.Copy(newV, a)
+	reflect.Copy(newV.Slice(a.Len(), newV.Len()), b)
+
+	return newV
+}
+
+// processKeyed processes the elements of the sourceV slice field merged by key (see StrategyTag) into the targetV
+// slice field. The keyVal is the value of the field in the keys map, holding the keys maps of the elements.
+// Path is the path of the struct containing the field.
+func (o *options) processKeyed(f *fieldPlan, targetV reflect.Value, sourceV reflect.Value, keyVal interface{}, path fieldPath, changes *[]change, merge bool) error {
+	elemType := targetV.Type().Elem()
+	plan, err := getPlan(structType(elemType), o.tag)
+	if err != nil {
+		return err
+	}
+
+	elemKeys, _ := keyVal.([]interface{})
+	changeCount := len(*changes)
+
+	// elements are processed on a copy of the target slice, so the indexes of the changes reflect the elements
+	// added and removed before them
+	resultV := reflect.MakeSlice(targetV.Type(), targetV.Len(), targetV.Len())
+	reflect.Copy(resultV, targetV)
+	for i := 0; i < sourceV.Len(); i++ {
+		elemV := sourceV.Index(i)
+		if elemV.Kind() == reflect.Ptr && elemV.IsNil() {
+			continue
+		}
+
+		idV, ok := plan.lookup(reflect.Indirect(elemV), f.key, false)
+		if !ok {
+			return errors.Errorf("field %v: key %q not found in %v", f.name, f.key, structType(elemType))
+		}
+
+		var keys map[string]interface{}
+		if i < len(elemKeys) {
+			keys, _ = elemKeys[i].(map[string]interface{})
+		}
+
+		step := pathStep{name: f.name, key: f.key, id: idV.Interface()}
+		j := o.indexByKey(plan, f.key, resultV, idV)
+		switch {
+		case keys[DeleteMarker] == true:
+			if j < 0 {
+				continue
+			}
+
+			step.index = j
+			*changes = append(*changes, change{
+				path:     path.child(step),
+				op:       PatchOpRemove,
+				oldValue: reflect.ValueOf(resultV.Index(j).Interface()),
+				newValue: reflect.Zero(elemType),
+			})
+			resultV = reflect.AppendSlice(resultV.Slice(0, j), resultV.Slice(j+1, resultV.Len()))
+
+		case j < 0:
+			step.index = resultV.Len()
+			*changes = append(*changes, change{
+				path:     path.child(step),
+				op:       PatchOpAdd,
+				oldValue: reflect.Zero(elemType),
+				newValue: elemV,
+			})
+			resultV = reflect.Append(resultV, elemV)
+
+		default:
+			step.index = j
+			err := processStructs(plan, reflect.Indirect(resultV.Index(j)), reflect.Indirect(elemV), o, keys, path.child(step), changes, merge)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if merge && len(*changes) > changeCount {
+		o.set(targetV, resultV)
+	}
+
+	return nil
+}
+
+// indexByKey returns the index of the element of sliceV whose key field equals idV, or -1 if there is none.
+func (o *options) indexByKey(plan *structPlan, key string, sliceV reflect.Value, idV reflect.Value) int {
+	for i := 0; i < sliceV.Len(); i++ {
+		elemV := sliceV.Index(i)
+		if elemV.Kind() == reflect.Ptr && elemV.IsNil() {
+			continue
+		}
+
+		if otherV, ok := plan.lookup(reflect.Indirect(elemV), key, false); ok && o.equal(otherV, idV, nil) {
+			return i
+		}
+	}
+
+	return -1
+}
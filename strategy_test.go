@@ -0,0 +1,309 @@
+package shallow
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+type strategyTest struct {
+	Tags      []string          `json:"tags" shallow:"append"`
+	Roles     []string          `json:"roles" shallow:"union"`
+	Labels    map[string]string `json:"labels" shallow:"union"`
+	Owner     string            `json:"owner" shallow:"keep"`
+	Nested    Nested            `json:"nested" shallow:"deep"`
+	NestedPtr *Nested           `json:"nested_ptr" shallow:"deep"`
+	Replaced  []string          `json:"replaced"`
+}
+
+func strategyTestData(modify func(strategyTest) strategyTest) strategyTest {
+	data := strategyTest{
+		Tags:   []string{"a"},
+		Roles:  []string{"admin"},
+		Labels: map[string]string{"env": "prod"},
+		Owner:  "owner",
+		Nested: Nested{
+			String: "nested_string_val",
+			Bool:   true,
+		},
+		NestedPtr: &Nested{
+			String: "nested_ptr_string_val",
+		},
+		Replaced: []string{"a"},
+	}
+
+	if modify != nil {
+		data = modify(data)
+	}
+
+	return data
+}
+
+func TestStrategy(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		current         strategyTest
+		incoming        string
+		want            strategyTest
+		wantChangedKeys []string
+	}{
+		"append": {
+			current:  strategyTestData(nil),
+			incoming: `{"tags":["a","b"],"replaced":["b"]}`,
+			want: strategyTestData(func(t strategyTest) strategyTest {
+				t.Tags = []string{"a", "a", "b"}
+				t.Replaced = []string{"b"}
+				return t
+			}),
+			wantChangedKeys: []string{"tags", "replaced"},
+		},
+		"append_empty": {
+			current:         strategyTestData(nil),
+			incoming:        `{"tags":null}`,
+			want:            strategyTestData(nil),
+			wantChangedKeys: []string{},
+		},
+		"union": {
+			current:  strategyTestData(nil),
+			incoming: `{"roles":["admin","user","user"],"labels":{"env":"dev","team":"core"}}`,
+			want: strategyTestData(func(t strategyTest) strategyTest {
+				t.Roles = []string{"admin", "user"}
+				t.Labels = map[string]string{"env": "dev", "team": "core"}
+				return t
+			}),
+			wantChangedKeys: []string{"roles", "labels"},
+		},
+		"union_same": {
+			current:         strategyTestData(nil),
+			incoming:        `{"roles":["admin"],"labels":{"env":"prod"}}`,
+			want:            strategyTestData(nil),
+			wantChangedKeys: []string{},
+		},
+		"keep": {
+			current:         strategyTestData(nil),
+			incoming:        `{"owner":"other"}`,
+			want:            strategyTestData(nil),
+			wantChangedKeys: []string{},
+		},
+		"keep_zero": {
+			current: strategyTestData(func(t strategyTest) strategyTest {
+				t.Owner = ""
+				return t
+			}),
+			incoming: `{"owner":"other"}`,
+			want: strategyTestData(func(t strategyTest) strategyTest {
+				t.Owner = "other"
+				return t
+			}),
+			wantChangedKeys: []string{"owner"},
+		},
+		"deep": {
+			current:  strategyTestData(nil),
+			incoming: `{"nested":{"bool":false},"nested_ptr":{"bool":true}}`,
+			want: strategyTestData(func(t strategyTest) strategyTest {
+				t.Nested.Bool = false
+				t.NestedPtr.Bool = true
+				return t
+			}),
+			wantChangedKeys: []string{"nested.bool", "nested_ptr.bool"},
+		},
+	} {
+		update := strategyTest{}
+		err := json.Unmarshal([]byte(data.incoming), &update)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		var keys map[string]interface{}
+		err = json.Unmarshal([]byte(data.incoming), &keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		orig := data.current
+		gotDiffKeys, err := Diff(&orig, &update, keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotDiffKeys); len(diff) > 0 {
+			t.Errorf("%v diffKeys: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(data.current, orig); len(diff) > 0 {
+			t.Errorf("%v: diff modified first: %v", name, diff)
+		}
+
+		gotChangedKeys, err := Merge(&orig, &update, keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.want, orig); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, diff)
+		}
+	}
+}
+
+func TestStrategyInvalid(t *testing.T) {
+	type unknownStrategy struct {
+		String string `json:"string" shallow:"unknown"`
+	}
+	type appendString struct {
+		String string `json:"string" shallow:"append"`
+	}
+	type deepSlice struct {
+		Strings []string `json:"strings" shallow:"deep"`
+	}
+	type multipleStrategies struct {
+		Strings []string `json:"strings" shallow:"append,union"`
+	}
+	type keyStrings struct {
+		Strings []string `json:"strings" shallow:"key=id"`
+	}
+	type unknownKey struct {
+		Items []keyedItem `json:"items" shallow:"key=unknown"`
+	}
+
+	for name, value := range map[string]interface{}{
+		"unknown":  &unknownStrategy{},
+		"append":   &appendString{},
+		"deep":     &deepSlice{},
+		"multiple": &multipleStrategies{},
+		"key":      &keyStrings{},
+		"key_unknown": &unknownKey{
+			Items: []keyedItem{{ID: 1}},
+		},
+	} {
+		_, err := Diff(value, value, nil)
+		if err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+type keyedItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Qty  int    `json:"qty"`
+}
+
+type keyedTest struct {
+	Items    []keyedItem  `json:"items" shallow:"key=id"`
+	ItemPtrs []*keyedItem `json:"item_ptrs" shallow:"key=id"`
+}
+
+func keyedTestData() keyedTest {
+	return keyedTest{
+		Items: []keyedItem{
+			{ID: 1, Name: "first", Qty: 1},
+			{ID: 2, Name: "second", Qty: 2},
+		},
+		ItemPtrs: []*keyedItem{
+			{ID: 1, Name: "first", Qty: 1},
+		},
+	}
+}
+
+func TestStrategyKey(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		incoming        string
+		want            func() keyedTest
+		wantChangedKeys []string
+	}{
+		"merge_element": {
+			incoming: `{"items":[{"id":2,"qty":5}]}`,
+			want: func() keyedTest {
+				t := keyedTestData()
+				t.Items[1].Qty = 5
+				return t
+			},
+			wantChangedKeys: []string{"items[id=2].qty"},
+		},
+		"merge_element_ptr": {
+			incoming: `{"item_ptrs":[{"id":1,"name":"changed"}]}`,
+			want: func() keyedTest {
+				t := keyedTestData()
+				t.ItemPtrs[0].Name = "changed"
+				return t
+			},
+			wantChangedKeys: []string{"item_ptrs[id=1].name"},
+		},
+		"append_element": {
+			incoming: `{"items":[{"id":3,"name":"third","qty":3}]}`,
+			want: func() keyedTest {
+				t := keyedTestData()
+				t.Items = append(t.Items, keyedItem{ID: 3, Name: "third", Qty: 3})
+				return t
+			},
+			wantChangedKeys: []string{"items[id=3]"},
+		},
+		"delete_element": {
+			incoming: `{"items":[{"id":1,"$delete":true},{"id":2,"qty":0},{"id":4,"$delete":true}]}`,
+			want: func() keyedTest {
+				t := keyedTestData()
+				t.Items = []keyedItem{{ID: 2, Name: "second", Qty: 0}}
+				return t
+			},
+			wantChangedKeys: []string{"items[id=1]", "items[id=2].qty"},
+		},
+		"same": {
+			incoming:        `{"items":[{"id":1,"name":"first"}],"item_ptrs":[{"id":1,"qty":1}]}`,
+			want:            keyedTestData,
+			wantChangedKeys: []string{},
+		},
+	} {
+		update := keyedTest{}
+		err := json.Unmarshal([]byte(data.incoming), &update)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		var keys map[string]interface{}
+		err = json.Unmarshal([]byte(data.incoming), &keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		orig := keyedTestData()
+		gotDiffKeys, err := Diff(&orig, &update, keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotDiffKeys); len(diff) > 0 {
+			t.Errorf("%v diffKeys: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(keyedTestData(), orig); len(diff) > 0 {
+			t.Errorf("%v: diff modified first: %v", name, diff)
+		}
+
+		patch, err := DiffPatch(&orig, &update, keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		patchJSON, err := json.Marshal(patch)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		patched := keyedTestData()
+		_, err = ApplyJSONPatch(&patched, patchJSON)
+		if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.want(), patched); len(diff) > 0 {
+			t.Errorf("%v patched: diffs (want/got): %v", name, diff)
+		}
+
+		gotChangedKeys, err := Merge(&orig, &update, keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.want(), orig); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, diff)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package shallow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UnknownKeysError is returned by Diff and Merge with the Strict option, if the keys map contains keys which don't
+// match any field.
+type UnknownKeysError struct {
+	// Keys not matching any field, with keys within nested fields returned as dotted paths (see Deep).
+	Keys []string
+}
+
+func (e *UnknownKeysError) Error() string {
+	return fmt.Sprintf("unknown keys: %v", strings.Join(e.Keys, ", "))
+}
+
+// Strict can be used to make Diff and Merge return an *UnknownKeysError if the keys map contains keys which don't
+// match any field (see CollectUnknownKeys), without processing any of the fields.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// CollectUnknownKeys can be used to get the keys of the keys map which don't match any field, and are ignored by
+// Diff and Merge. Anonym fields are traversed the same way as by Diff and Merge.
+// Nested keys maps are checked if the fields are processed recursively (see Deep), and the keys within them are
+// returned as dotted paths, e.g. "nested.unknown". Keys within elements of slices merged by key are returned with
+// the index of the element in the keys map, e.g. "items[0].unknown".
+func CollectUnknownKeys(unknownKeys *[]string) Option {
+	return func(o *options) {
+		o.unknownKeys = unknownKeys
+	}
+}
+
+// findUnknownKeys returns the keys of the keys map which don't match any field of the plan, prefixed with prefix.
+// DeleteMarker is a known key if the keys map belongs to an element of a slice merged by key.
+func (o *options) findUnknownKeys(plan *structPlan, keys map[string]interface{}, prefix string, element bool) ([]string, error) {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	unknown := make([]string, 0)
+	for _, name := range names {
+		f := plan.find(name)
+		if f == nil {
+			if name != DeleteMarker || !element {
+				unknown = append(unknown, prefix+name)
+			}
+			continue
+		}
+
+		switch keyVal := keys[name].(type) {
+		case map[string]interface{}:
+			deep := f.strategy == strategyDeep || (o.deep && o.comparator(f.typ) == nil)
+			if !deep || !f.nested {
+				continue
+			}
+
+			nestedPlan, err := getPlan(structType(f.typ), o.tag)
+			if err != nil {
+				return nil, err
+			}
+			if len(nestedPlan.fields) == 0 {
+				continue
+			}
+			nestedUnknown, err := o.findUnknownKeys(nestedPlan, keyVal, prefix+name+".", false)
+			if err != nil {
+				return nil, err
+			}
+			unknown = append(unknown, nestedUnknown...)
+
+		case []interface{}:
+			if f.strategy != strategyKey {
+				continue
+			}
+
+			elemPlan, err := getPlan(structType(f.typ.Elem()), o.tag)
+			if err != nil {
+				return nil, err
+			}
+			for i, elemKeys := range keyVal {
+				elemKeys, ok := elemKeys.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				elemUnknown, err := o.findUnknownKeys(elemPlan, elemKeys, fmt.Sprintf("%v%v[%v].", prefix, name, i), true)
+				if err != nil {
+					return nil, err
+				}
+				unknown = append(unknown, elemUnknown...)
+			}
+		}
+	}
+
+	return unknown, nil
+}
@@ -0,0 +1,98 @@
+package shallow
+
+import (
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+func TestStrict(t *testing.T) {
+
+	for name, data := range map[string]struct {
+		keys        map[string]interface{}
+		opts        []Option
+		wantUnknown []string
+	}{
+		"known": {
+			keys: map[string]interface{}{
+				"string":            nil,
+				"anonym_string":     nil,
+				"anonym_ptr_string": nil,
+				"anonym_ptr2_bool":  nil,
+			},
+			wantUnknown: []string{},
+		},
+		"unknown": {
+			keys: map[string]interface{}{
+				"string":       nil,
+				"nmae":         nil,
+				"AnonymString": nil,
+			},
+			wantUnknown: []string{"AnonymString", "nmae"},
+		},
+		"nested_shallow": {
+			keys: map[string]interface{}{
+				"nested": map[string]interface{}{"unknown": nil},
+			},
+			wantUnknown: []string{},
+		},
+		"nested_deep": {
+			keys: map[string]interface{}{
+				"nested":     map[string]interface{}{"string": nil, "unknown": nil},
+				"nested_ptr": map[string]interface{}{"unknown": nil},
+			},
+			opts:        []Option{Deep()},
+			wantUnknown: []string{"nested.unknown", "nested_ptr.unknown"},
+		},
+	} {
+		got := testData(nil)
+		update := testData(nil)
+
+		var gotUnknown []string
+		_, err := Merge(&got, &update, data.keys, append(data.opts, CollectUnknownKeys(&gotUnknown))...)
+		if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+		if diff := pretty.Diff(data.wantUnknown, gotUnknown); len(diff) > 0 {
+			t.Errorf("%v unknown: diffs (want/got): %v", name, diff)
+		}
+
+		_, err = Merge(&got, &update, data.keys, append(data.opts, Strict())...)
+		if len(data.wantUnknown) == 0 {
+			if err != nil {
+				t.Fatalf("%v: %+v", name, errors.WithStack(err))
+			}
+			continue
+		}
+		unknownErr, ok := err.(*UnknownKeysError)
+		if !ok {
+			t.Fatalf("%v: expected *UnknownKeysError, got: %v", name, err)
+		}
+		if diff := pretty.Diff(data.wantUnknown, unknownErr.Keys); len(diff) > 0 {
+			t.Errorf("%v strict: diffs (want/got): %v", name, diff)
+		}
+	}
+}
+
+func TestStrictKeyed(t *testing.T) {
+	got := keyedTestData()
+	update := keyedTestData()
+	keys := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"id": 1, DeleteMarker: true},
+			map[string]interface{}{"id": 2, "qyt": 3},
+		},
+	}
+
+	var gotUnknown []string
+	_, err := Merge(&got, &update, keys, CollectUnknownKeys(&gotUnknown))
+	if err != nil {
+		t.Fatalf("%+v", errors.WithStack(err))
+	}
+
+	wantUnknown := []string{"items[1].qyt"}
+	if diff := pretty.Diff(wantUnknown, gotUnknown); len(diff) > 0 {
+		t.Errorf("unknown: diffs (want/got): %v", diff)
+	}
+}
@@ -0,0 +1,71 @@
+package shallow
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ValidationError is returned by Merge if dest is invalid after applying the changes (see WithValidation).
+// The changes are rolled back, leaving dest untouched.
+type ValidationError struct {
+	// Keys of the rolled back changes, in the same format as the keys returned by Merge.
+	Keys []string
+	// Err is the error returned by the validator.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validation failed, rolled back changes of %v: %v", strings.Join(e.Keys, ", "), e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// WithValidation can be used to validate dest after Merge applied the changes, with the given validator.
+// If validator is nil, the Validate() error method of dest is used, if it has one.
+// If the validation fails, every changed field is restored to its previous value, and a *ValidationError is returned.
+//
+// Dest is not validated if Merge changed nothing.
+func WithValidation(validator func(dest interface{}) error) Option {
+	return func(o *options) {
+		o.validation = true
+		o.validator = validator
+	}
+}
+
+type undoEntry struct {
+	v   reflect.Value
+	old reflect.Value
+}
+
+// set sets v to newV, recording the previous value of v if the changes may have to be rolled back.
+func (o *options) set(v reflect.Value, newV reflect.Value) {
+	if o.validation {
+		old := reflect.New(v.Type()).Elem()
+		old.Set(v)
+		o.undo = append(o.undo, undoEntry{v: v, old: old})
+	}
+
+	v.Set(newV)
+}
+
+// rollback restores the values changed by set.
+func (o *options) rollback() {
+	for i := len(o.undo) - 1; i >= 0; i-- {
+		o.undo[i].v.Set(o.undo[i].old)
+	}
+	o.undo = nil
+}
+
+func (o *options) validate(dest interface{}) error {
+	if o.validator != nil {
+		return o.validator(dest)
+	}
+	if v, ok := dest.(interface{ Validate() error }); ok {
+		return v.Validate()
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package shallow
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/proemergotech/errors/v2"
+)
+
+type validatedTest struct {
+	*AnonymPtr
+	Items  []keyedItem `json:"items" shallow:"key=id"`
+	Min    int         `json:"min"`
+	Max    int         `json:"max"`
+	Nested *Nested     `json:"nested"`
+}
+
+func (v *validatedTest) Validate() error {
+	if v.Min > v.Max {
+		return errors.New("min must not be greater than max")
+	}
+
+	return nil
+}
+
+func TestMergeValidation(t *testing.T) {
+	current := func() validatedTest {
+		return validatedTest{
+			Items: []keyedItem{{ID: 1, Qty: 1}},
+			Min:   1,
+			Max:   2,
+		}
+	}
+
+	for name, data := range map[string]struct {
+		incoming        string
+		want            validatedTest
+		wantChangedKeys []string
+		wantRolledBack  []string
+	}{
+		"valid": {
+			incoming: `{"min":2,"nested":{"string":"nested_string_val"}}`,
+			want: func() validatedTest {
+				t := current()
+				t.Min = 2
+				t.Nested = &Nested{String: "nested_string_val"}
+				return t
+			}(),
+			wantChangedKeys: []string{"min", "nested"},
+		},
+		"invalid": {
+			incoming:       `{"items":[{"id":1,"qty":2},{"id":2}],"anonym_ptr_string":"anonym","min":3,"nested":{"string":"nested_string_val"}}`,
+			want:           current(),
+			wantRolledBack: []string{"anonym_ptr_string", "items[id=1].qty", "items[id=2]", "min", "nested"},
+		},
+	} {
+		update := validatedTest{}
+		err := json.Unmarshal([]byte(data.incoming), &update)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+		var keys map[string]interface{}
+		err = json.Unmarshal([]byte(data.incoming), &keys)
+		if err != nil {
+			t.Fatalf("%+v", errors.WithStack(err))
+		}
+
+		got := current()
+		gotChangedKeys, err := Merge(&got, &update, keys, Deep(), WithValidation(nil))
+		if len(data.wantRolledBack) > 0 {
+			validationErr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("%v: expected *ValidationError, got: %v", name, err)
+			}
+			if diff := pretty.Diff(data.wantRolledBack, validationErr.Keys); len(diff) > 0 {
+				t.Errorf("%v rolled back: diffs (want/got): %v", name, diff)
+			}
+		} else if err != nil {
+			t.Fatalf("%v: %+v", name, errors.WithStack(err))
+		}
+
+		if diff := pretty.Diff(data.want, got); len(diff) > 0 {
+			t.Errorf("%v: diffs (want/got): %v", name, diff)
+		}
+		if diff := pretty.Diff(data.wantChangedKeys, gotChangedKeys); len(diff) > 0 {
+			t.Errorf("%v changedKeys: diffs (want/got): %v", name, diff)
+		}
+	}
+}
+
+func TestMergeValidator(t *testing.T) {
+	got := testData(nil)
+	update := testData(func(t test) test {
+		t.String = "invalid"
+		return t
+	})
+
+	validationErr := errors.New("invalid string")
+	_, err := Merge(&got, &update, map[string]interface{}{"string": nil}, WithValidation(func(dest interface{}) error {
+		if dest.(*test).String == "invalid" {
+			return validationErr
+		}
+		return nil
+	}))
+	if err == nil || err.(*ValidationError).Err != validationErr {
+		t.Fatalf("expected validation error, got: %v", err)
+	}
+	if diff := pretty.Diff(testData(nil), got); len(diff) > 0 {
+		t.Errorf("diffs (want/got): %v", diff)
+	}
+}